@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	"github.com/aws/aws-sdk-go-v2/service/forecastquery"
+)
+
+// ForecastBackend is the subset of the Forecast API used by this function. *forecast.Client
+// satisfies it, as does the in-process localBackend.
+type ForecastBackend interface {
+	CreateDatasetGroup(ctx context.Context, params *forecast.CreateDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetGroupOutput, error)
+	CreateDataset(ctx context.Context, params *forecast.CreateDatasetInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetOutput, error)
+	CreateDatasetImportJob(ctx context.Context, params *forecast.CreateDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetImportJobOutput, error)
+	CreatePredictor(ctx context.Context, params *forecast.CreatePredictorInput, optFns ...func(*forecast.Options)) (*forecast.CreatePredictorOutput, error)
+	CreateAutoPredictor(ctx context.Context, params *forecast.CreateAutoPredictorInput, optFns ...func(*forecast.Options)) (*forecast.CreateAutoPredictorOutput, error)
+	CreateForecast(ctx context.Context, params *forecast.CreateForecastInput, optFns ...func(*forecast.Options)) (*forecast.CreateForecastOutput, error)
+	CreateForecastExportJob(ctx context.Context, params *forecast.CreateForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.CreateForecastExportJobOutput, error)
+	CreateExplainability(ctx context.Context, params *forecast.CreateExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.CreateExplainabilityOutput, error)
+	CreateExplainabilityExport(ctx context.Context, params *forecast.CreateExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.CreateExplainabilityExportOutput, error)
+	CreateWhatIfAnalysis(ctx context.Context, params *forecast.CreateWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfAnalysisOutput, error)
+	CreateWhatIfForecast(ctx context.Context, params *forecast.CreateWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfForecastOutput, error)
+	CreateWhatIfForecastExport(ctx context.Context, params *forecast.CreateWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfForecastExportOutput, error)
+	ListDatasetGroups(ctx context.Context, params *forecast.ListDatasetGroupsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetGroupsOutput, error)
+	ListDatasets(ctx context.Context, params *forecast.ListDatasetsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetsOutput, error)
+	ListDatasetImportJobs(ctx context.Context, params *forecast.ListDatasetImportJobsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetImportJobsOutput, error)
+	ListPredictors(ctx context.Context, params *forecast.ListPredictorsInput, optFns ...func(*forecast.Options)) (*forecast.ListPredictorsOutput, error)
+	ListForecasts(ctx context.Context, params *forecast.ListForecastsInput, optFns ...func(*forecast.Options)) (*forecast.ListForecastsOutput, error)
+	ListForecastExportJobs(ctx context.Context, params *forecast.ListForecastExportJobsInput, optFns ...func(*forecast.Options)) (*forecast.ListForecastExportJobsOutput, error)
+	ListExplainabilities(ctx context.Context, params *forecast.ListExplainabilitiesInput, optFns ...func(*forecast.Options)) (*forecast.ListExplainabilitiesOutput, error)
+	ListExplainabilityExports(ctx context.Context, params *forecast.ListExplainabilityExportsInput, optFns ...func(*forecast.Options)) (*forecast.ListExplainabilityExportsOutput, error)
+	ListWhatIfAnalyses(ctx context.Context, params *forecast.ListWhatIfAnalysesInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfAnalysesOutput, error)
+	ListWhatIfForecasts(ctx context.Context, params *forecast.ListWhatIfForecastsInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfForecastsOutput, error)
+	ListWhatIfForecastExports(ctx context.Context, params *forecast.ListWhatIfForecastExportsInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfForecastExportsOutput, error)
+	DescribeDatasetGroup(ctx context.Context, params *forecast.DescribeDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetGroupOutput, error)
+	DescribeDataset(ctx context.Context, params *forecast.DescribeDatasetInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetOutput, error)
+	DescribeDatasetImportJob(ctx context.Context, params *forecast.DescribeDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetImportJobOutput, error)
+	DescribePredictor(ctx context.Context, params *forecast.DescribePredictorInput, optFns ...func(*forecast.Options)) (*forecast.DescribePredictorOutput, error)
+	DescribeAutoPredictor(ctx context.Context, params *forecast.DescribeAutoPredictorInput, optFns ...func(*forecast.Options)) (*forecast.DescribeAutoPredictorOutput, error)
+	DescribeForecast(ctx context.Context, params *forecast.DescribeForecastInput, optFns ...func(*forecast.Options)) (*forecast.DescribeForecastOutput, error)
+	DescribeForecastExportJob(ctx context.Context, params *forecast.DescribeForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.DescribeForecastExportJobOutput, error)
+	DescribeExplainability(ctx context.Context, params *forecast.DescribeExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.DescribeExplainabilityOutput, error)
+	DescribeExplainabilityExport(ctx context.Context, params *forecast.DescribeExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.DescribeExplainabilityExportOutput, error)
+	DescribeWhatIfAnalysis(ctx context.Context, params *forecast.DescribeWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfAnalysisOutput, error)
+	DescribeWhatIfForecast(ctx context.Context, params *forecast.DescribeWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfForecastOutput, error)
+	DescribeWhatIfForecastExport(ctx context.Context, params *forecast.DescribeWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfForecastExportOutput, error)
+	DeleteDatasetGroup(ctx context.Context, params *forecast.DeleteDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetGroupOutput, error)
+	DeleteDataset(ctx context.Context, params *forecast.DeleteDatasetInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetOutput, error)
+	DeleteDatasetImportJob(ctx context.Context, params *forecast.DeleteDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetImportJobOutput, error)
+	DeletePredictor(ctx context.Context, params *forecast.DeletePredictorInput, optFns ...func(*forecast.Options)) (*forecast.DeletePredictorOutput, error)
+	DeleteForecast(ctx context.Context, params *forecast.DeleteForecastInput, optFns ...func(*forecast.Options)) (*forecast.DeleteForecastOutput, error)
+	DeleteForecastExportJob(ctx context.Context, params *forecast.DeleteForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.DeleteForecastExportJobOutput, error)
+	DeleteExplainability(ctx context.Context, params *forecast.DeleteExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.DeleteExplainabilityOutput, error)
+	DeleteExplainabilityExport(ctx context.Context, params *forecast.DeleteExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.DeleteExplainabilityExportOutput, error)
+	DeleteWhatIfAnalysis(ctx context.Context, params *forecast.DeleteWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfAnalysisOutput, error)
+	DeleteWhatIfForecast(ctx context.Context, params *forecast.DeleteWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfForecastOutput, error)
+	DeleteWhatIfForecastExport(ctx context.Context, params *forecast.DeleteWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfForecastExportOutput, error)
+	GetAccuracyMetrics(ctx context.Context, params *forecast.GetAccuracyMetricsInput, optFns ...func(*forecast.Options)) (*forecast.GetAccuracyMetricsOutput, error)
+	UpdateDatasetGroup(ctx context.Context, params *forecast.UpdateDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.UpdateDatasetGroupOutput, error)
+}
+
+// ForecastQueryBackend reads forecast values without an export job. *forecastquery.Client satisfies
+// it, as does localBackend.
+type ForecastQueryBackend interface {
+	QueryForecast(ctx context.Context, params *forecastquery.QueryForecastInput, optFns ...func(*forecastquery.Options)) (*forecastquery.QueryForecastOutput, error)
+}
+
+const backendLocal string = "local"
+
+// getForecastClient returns the backend selected by FORECAST_BACKEND. "local" runs every step
+// in-process; anything else uses Amazon Forecast.
+func getForecastClient(ctx context.Context) ForecastBackend {
+	if os.Getenv("FORECAST_BACKEND") == backendLocal {
+		return newLocalBackend()
+	}
+	return forecast.NewFromConfig(getConfig(ctx))
+}
+
+// getForecastQueryClient returns the query backend matching FORECAST_BACKEND. The local backend
+// answers from the same in-memory forecasts as forecastClient.
+func getForecastQueryClient(ctx context.Context) ForecastQueryBackend {
+	if os.Getenv("FORECAST_BACKEND") == backendLocal {
+		if forecastClient == nil {
+			forecastClient = getForecastClient(ctx)
+		}
+		if b, ok := forecastClient.(*localBackend); ok {
+			return b
+		}
+	}
+	return forecastquery.NewFromConfig(getConfig(ctx))
+}
@@ -0,0 +1,1438 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"bytes"
+	"strconv"
+	"strings"
+	"context"
+	"io/ioutil"
+	"encoding/csv"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+	"github.com/aws/aws-sdk-go-v2/service/forecastquery"
+	qtypes "github.com/aws/aws-sdk-go-v2/service/forecastquery/types"
+)
+
+const localArnPrefix     string = "arn:local:forecast:::"
+const localStatusActive  string = "ACTIVE"
+const localStatusFailed  string = "CREATE_FAILED"
+const exportDateLayout   string = "2006-01-02T15:04:05Z"
+const queryDateLayout    string = "2006-01-02T15:04:05"
+
+var defaultForecastTypes = []string{"0.1", "0.5", "0.9"}
+
+type localSeries struct {
+	timestamps []time.Time
+	values     []float64
+}
+
+type localResource struct {
+	name     string
+	arn      string
+	status   string
+	message  string
+	created  time.Time
+	modified time.Time
+}
+
+type localDatasetGroup struct {
+	localResource
+	domain      ftypes.Domain
+	datasetArns []string
+}
+
+type localDataset struct {
+	localResource
+	datasetType ftypes.DatasetType
+	domain      ftypes.Domain
+	frequency   string
+	schema      *ftypes.Schema
+	series      map[string]*localSeries
+	related     map[string]map[string]*localSeries
+}
+
+type localDatasetImportJob struct {
+	localResource
+	datasetArn string
+	path       string
+}
+
+// localPredictor keeps the training options for DescribePredictor; every algorithm is served by the
+// same Holt-Winters model.
+type localPredictor struct {
+	localResource
+	datasetGroupArn string
+	frequency       string
+	horizon         int
+	forecastTypes   []string
+	algorithmArn    string
+	autoML          bool
+	auto            bool
+	features        []ftypes.SupplementaryFeature
+	evaluation      *ftypes.EvaluationParameters
+}
+
+type localForecast struct {
+	localResource
+	predictorArn    string
+	datasetGroupArn string
+	forecastTypes   []string
+	itemField       string
+	rows            [][]string
+}
+
+type localForecastExportJob struct {
+	localResource
+	forecastArn string
+	path        string
+}
+
+type localExplainability struct {
+	localResource
+	resourceArn string
+	impacts     map[string]float64
+}
+
+type localExplainabilityExport struct {
+	localResource
+	explainabilityArn string
+	path              string
+}
+
+type localWhatIfAnalysis struct {
+	localResource
+	forecastArn string
+}
+
+type localWhatIfForecast struct {
+	localResource
+	whatIfAnalysisArn string
+	forecastTypes     []string
+	itemField         string
+	rows              [][]string
+}
+
+type localWhatIfForecastExport struct {
+	localResource
+	whatIfForecastArns []string
+	path               string
+}
+
+// localBackend runs dataset import, training, forecasting and export in-process with a Holt-Winters
+// model. Every create call finishes synchronously, so resources are ACTIVE (or CREATE_FAILED) as
+// soon as they exist. State lives in memory for the lifetime of the process.
+type localBackend struct {
+	mu                 sync.Mutex
+	datasetGroups      map[string]*localDatasetGroup
+	datasets           map[string]*localDataset
+	datasetImportJobs  map[string]*localDatasetImportJob
+	predictors         map[string]*localPredictor
+	forecasts          map[string]*localForecast
+	forecastExportJobs map[string]*localForecastExportJob
+	explainabilities   map[string]*localExplainability
+	explainExports     map[string]*localExplainabilityExport
+	whatIfAnalyses     map[string]*localWhatIfAnalysis
+	whatIfForecasts    map[string]*localWhatIfForecast
+	whatIfExports      map[string]*localWhatIfForecastExport
+}
+
+func newLocalBackend() *localBackend {
+	return &localBackend{
+		datasetGroups:      make(map[string]*localDatasetGroup),
+		datasets:           make(map[string]*localDataset),
+		datasetImportJobs:  make(map[string]*localDatasetImportJob),
+		predictors:         make(map[string]*localPredictor),
+		forecasts:          make(map[string]*localForecast),
+		forecastExportJobs: make(map[string]*localForecastExportJob),
+		explainabilities:   make(map[string]*localExplainability),
+		explainExports:     make(map[string]*localExplainabilityExport),
+		whatIfAnalyses:     make(map[string]*localWhatIfAnalysis),
+		whatIfForecasts:    make(map[string]*localWhatIfForecast),
+		whatIfExports:      make(map[string]*localWhatIfForecastExport),
+	}
+}
+
+func newLocalResource(kind string, name string) localResource {
+	t := time.Now()
+	return localResource{
+		name:     name,
+		arn:      localArnPrefix + kind + "/" + name,
+		status:   localStatusActive,
+		created:  t,
+		modified: t,
+	}
+}
+
+func (r *localResource) fail(err error) {
+	r.status = localStatusFailed
+	r.message = err.Error()
+}
+
+func alreadyExists(name string) error {
+	return &ftypes.ResourceAlreadyExistsException{Message: aws.String("Resource " + name + " already exists.")}
+}
+
+func notFound(arn string) error {
+	return &ftypes.ResourceNotFoundException{Message: aws.String("Resource " + arn + " not found.")}
+}
+
+func inUse(arn string, by string) error {
+	return &ftypes.ResourceInUseException{Message: aws.String("Resource " + arn + " is used by " + by + ".")}
+}
+
+func (b *localBackend) CreateDatasetGroup(ctx context.Context, params *forecast.CreateDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetGroupOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("dataset-group", aws.ToString(params.DatasetGroupName))
+	if _, ok := b.datasetGroups[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	b.datasetGroups[r.arn] = &localDatasetGroup{localResource: r, domain: params.Domain, datasetArns: params.DatasetArns}
+	return &forecast.CreateDatasetGroupOutput{DatasetGroupArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateDataset(ctx context.Context, params *forecast.CreateDatasetInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("dataset", aws.ToString(params.DatasetName))
+	if _, ok := b.datasets[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	b.datasets[r.arn] = &localDataset{
+		localResource: r,
+		datasetType:   params.DatasetType,
+		domain:        params.Domain,
+		frequency:     aws.ToString(params.DataFrequency),
+		schema:        params.Schema,
+	}
+	return &forecast.CreateDatasetOutput{DatasetArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateDatasetImportJob(ctx context.Context, params *forecast.CreateDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.CreateDatasetImportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("dataset-import-job", aws.ToString(params.DatasetImportJobName))
+	if _, ok := b.datasetImportJobs[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	ds, ok := b.datasets[aws.ToString(params.DatasetArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DatasetArn))
+	}
+	job := &localDatasetImportJob{localResource: r, datasetArn: ds.arn}
+	if params.DataSource != nil && params.DataSource.S3Config != nil {
+		job.path = aws.ToString(params.DataSource.S3Config.Path)
+	}
+	data, err := readLocalPath(ctx, job.path)
+	// The local model only uses the target series; related series are only read for explainability.
+	if err == nil && ds.datasetType == ftypes.DatasetTypeTargetTimeSeries {
+		ds.series, err = parseLocalDataset(data, ds.schema)
+	} else if err == nil && ds.datasetType == ftypes.DatasetTypeRelatedTimeSeries {
+		ds.related, err = parseLocalRelated(data, ds.schema)
+	}
+	if err != nil {
+		job.fail(err)
+	}
+	b.datasetImportJobs[r.arn] = job
+	return &forecast.CreateDatasetImportJobOutput{DatasetImportJobArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreatePredictor(ctx context.Context, params *forecast.CreatePredictorInput, optFns ...func(*forecast.Options)) (*forecast.CreatePredictorOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("predictor", aws.ToString(params.PredictorName))
+	if _, ok := b.predictors[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	if params.InputDataConfig == nil {
+		return nil, notFound("")
+	}
+	dsg, ok := b.datasetGroups[aws.ToString(params.InputDataConfig.DatasetGroupArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.InputDataConfig.DatasetGroupArn))
+	}
+	p := &localPredictor{
+		localResource:   r,
+		datasetGroupArn: dsg.arn,
+		horizon:         int(aws.ToInt32(params.ForecastHorizon)),
+		forecastTypes:   params.ForecastTypes,
+		algorithmArn:    aws.ToString(params.AlgorithmArn),
+		autoML:          aws.ToBool(params.PerformAutoML),
+		features:        params.InputDataConfig.SupplementaryFeatures,
+		evaluation:      params.EvaluationParameters,
+	}
+	if params.FeaturizationConfig != nil {
+		p.frequency = aws.ToString(params.FeaturizationConfig.ForecastFrequency)
+	}
+	if len(p.forecastTypes) == 0 {
+		p.forecastTypes = defaultForecastTypes
+	}
+	if _, err := b.targetDataset(dsg); err != nil {
+		p.fail(err)
+	}
+	b.predictors[r.arn] = p
+	return &forecast.CreatePredictorOutput{PredictorArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateAutoPredictor(ctx context.Context, params *forecast.CreateAutoPredictorInput, optFns ...func(*forecast.Options)) (*forecast.CreateAutoPredictorOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("predictor", aws.ToString(params.PredictorName))
+	if _, ok := b.predictors[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	if params.DataConfig == nil {
+		return nil, notFound("")
+	}
+	dsg, ok := b.datasetGroups[aws.ToString(params.DataConfig.DatasetGroupArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DataConfig.DatasetGroupArn))
+	}
+	p := &localPredictor{
+		localResource:   r,
+		datasetGroupArn: dsg.arn,
+		frequency:       aws.ToString(params.ForecastFrequency),
+		horizon:         int(aws.ToInt32(params.ForecastHorizon)),
+		forecastTypes:   params.ForecastTypes,
+		autoML:          true,
+		auto:            true,
+	}
+	if len(p.forecastTypes) == 0 {
+		p.forecastTypes = defaultForecastTypes
+	}
+	if _, err := b.targetDataset(dsg); err != nil {
+		p.fail(err)
+	}
+	b.predictors[r.arn] = p
+	return &forecast.CreateAutoPredictorOutput{PredictorArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateForecast(ctx context.Context, params *forecast.CreateForecastInput, optFns ...func(*forecast.Options)) (*forecast.CreateForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("forecast", aws.ToString(params.ForecastName))
+	if _, ok := b.forecasts[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	p, ok := b.predictors[aws.ToString(params.PredictorArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.PredictorArn))
+	}
+	f := &localForecast{
+		localResource:   r,
+		predictorArn:    p.arn,
+		datasetGroupArn: p.datasetGroupArn,
+		forecastTypes:   params.ForecastTypes,
+		itemField:       "item_id",
+	}
+	if len(f.forecastTypes) == 0 {
+		f.forecastTypes = p.forecastTypes
+	}
+	ds, err := b.targetDataset(b.datasetGroups[p.datasetGroupArn])
+	if err == nil {
+		f.itemField, _, _ = localSchemaFields(ds.schema)
+		f.rows, err = predictLocal(ds, p, f.forecastTypes)
+	}
+	if err != nil {
+		f.fail(err)
+	}
+	b.forecasts[r.arn] = f
+	return &forecast.CreateForecastOutput{ForecastArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateForecastExportJob(ctx context.Context, params *forecast.CreateForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.CreateForecastExportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("forecast-export-job", aws.ToString(params.ForecastExportJobName))
+	if _, ok := b.forecastExportJobs[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	f, ok := b.forecasts[aws.ToString(params.ForecastArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ForecastArn))
+	}
+	job := &localForecastExportJob{localResource: r, forecastArn: f.arn}
+	if params.Destination != nil && params.Destination.S3Config != nil {
+		job.path = aws.ToString(params.Destination.S3Config.Path)
+	}
+	filename := r.name + "_" + r.created.UTC().Format("2006-01-02T15-04-05Z") + "_part0.csv"
+	if data, err := localExportData(f.itemField, f.forecastTypes, f.rows); err != nil {
+		job.fail(err)
+	} else if err := writeLocalPath(ctx, strings.TrimSuffix(job.path, "/") + "/" + filename, data); err != nil {
+		job.fail(err)
+	}
+	b.forecastExportJobs[r.arn] = job
+	return &forecast.CreateForecastExportJobOutput{ForecastExportJobArn: aws.String(r.arn)}, nil
+}
+
+// CreateExplainability scores the related time series of the predictor's dataset group with
+// localImpacts. A dataset group without related time series has no attributes to score.
+func (b *localBackend) CreateExplainability(ctx context.Context, params *forecast.CreateExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.CreateExplainabilityOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("explainability", aws.ToString(params.ExplainabilityName))
+	if _, ok := b.explainabilities[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	p, ok := b.predictors[aws.ToString(params.ResourceArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ResourceArn))
+	}
+	e := &localExplainability{localResource: r, resourceArn: p.arn}
+	dsg := b.datasetGroups[p.datasetGroupArn]
+	ds, err := b.targetDataset(dsg)
+	if err == nil {
+		e.impacts = localImpacts(ds.series, b.relatedSeries(dsg))
+	}
+	if err != nil {
+		e.fail(err)
+	}
+	b.explainabilities[r.arn] = e
+	return &forecast.CreateExplainabilityOutput{ExplainabilityArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateExplainabilityExport(ctx context.Context, params *forecast.CreateExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.CreateExplainabilityExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("explainability-export", aws.ToString(params.ExplainabilityExportName))
+	if _, ok := b.explainExports[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	e, ok := b.explainabilities[aws.ToString(params.ExplainabilityArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ExplainabilityArn))
+	}
+	job := &localExplainabilityExport{localResource: r, explainabilityArn: e.arn}
+	if params.Destination != nil && params.Destination.S3Config != nil {
+		job.path = aws.ToString(params.Destination.S3Config.Path)
+	}
+	buf := new(bytes.Buffer)
+	filename := r.name + "_" + r.created.UTC().Format("2006-01-02T15-04-05Z") + "_part0.csv"
+	if err := writeLocalImpacts(buf, e.impacts); err != nil {
+		job.fail(err)
+	} else if err := writeLocalPath(ctx, strings.TrimSuffix(job.path, "/") + "/" + filename, buf.Bytes()); err != nil {
+		job.fail(err)
+	}
+	b.explainExports[r.arn] = job
+	return &forecast.CreateExplainabilityExportOutput{ExplainabilityExportArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) CreateWhatIfAnalysis(ctx context.Context, params *forecast.CreateWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfAnalysisOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("what-if-analysis", aws.ToString(params.WhatIfAnalysisName))
+	if _, ok := b.whatIfAnalyses[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	f, ok := b.forecasts[aws.ToString(params.ForecastArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ForecastArn))
+	}
+	b.whatIfAnalyses[r.arn] = &localWhatIfAnalysis{localResource: r, forecastArn: f.arn}
+	return &forecast.CreateWhatIfAnalysisOutput{WhatIfAnalysisArn: aws.String(r.arn)}, nil
+}
+
+// CreateWhatIfForecast applies the transformations to the rows of the analysed forecast with
+// localScenarioRows. Replacement datasets are not supported.
+func (b *localBackend) CreateWhatIfForecast(ctx context.Context, params *forecast.CreateWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("what-if-forecast", aws.ToString(params.WhatIfForecastName))
+	if _, ok := b.whatIfForecasts[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	a, ok := b.whatIfAnalyses[aws.ToString(params.WhatIfAnalysisArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.WhatIfAnalysisArn))
+	}
+	f := b.forecasts[a.forecastArn]
+	w := &localWhatIfForecast{localResource: r, whatIfAnalysisArn: a.arn}
+	var err error
+	if f == nil {
+		err = fmt.Errorf("Error: %s", "No Forecast.")
+	} else {
+		w.forecastTypes, w.itemField = f.forecastTypes, f.itemField
+		dsg := b.datasetGroups[f.datasetGroupArn]
+		var ds *localDataset
+		if ds, err = b.targetDataset(dsg); err == nil {
+			w.rows, err = localScenarioRows(f.rows, f.itemField, ds.series, b.relatedSeries(dsg), params.TimeSeriesTransformations)
+		}
+	}
+	if err != nil {
+		w.fail(err)
+	}
+	b.whatIfForecasts[r.arn] = w
+	return &forecast.CreateWhatIfForecastOutput{WhatIfForecastArn: aws.String(r.arn)}, nil
+}
+
+// CreateWhatIfForecastExport writes one file per what-if forecast in the forecast export layout.
+func (b *localBackend) CreateWhatIfForecastExport(ctx context.Context, params *forecast.CreateWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.CreateWhatIfForecastExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := newLocalResource("what-if-forecast-export", aws.ToString(params.WhatIfForecastExportName))
+	if _, ok := b.whatIfExports[r.arn]; ok {
+		return nil, alreadyExists(r.name)
+	}
+	for _, v := range params.WhatIfForecastArns {
+		if _, ok := b.whatIfForecasts[v]; !ok {
+			return nil, notFound(v)
+		}
+	}
+	job := &localWhatIfForecastExport{localResource: r, whatIfForecastArns: params.WhatIfForecastArns}
+	if params.Destination != nil && params.Destination.S3Config != nil {
+		job.path = aws.ToString(params.Destination.S3Config.Path)
+	}
+	for i, v := range params.WhatIfForecastArns {
+		w := b.whatIfForecasts[v]
+		filename := r.name + "_" + r.created.UTC().Format("2006-01-02T15-04-05Z") + "_part" + strconv.Itoa(i) + ".csv"
+		if data, err := localExportData(w.itemField, w.forecastTypes, w.rows); err != nil {
+			job.fail(err)
+			break
+		} else if err := writeLocalPath(ctx, strings.TrimSuffix(job.path, "/") + "/" + filename, data); err != nil {
+			job.fail(err)
+			break
+		}
+	}
+	b.whatIfExports[r.arn] = job
+	return &forecast.CreateWhatIfForecastExportOutput{WhatIfForecastExportArn: aws.String(r.arn)}, nil
+}
+
+func (b *localBackend) ListDatasetGroups(ctx context.Context, params *forecast.ListDatasetGroupsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetGroupsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListDatasetGroupsOutput{}
+	for _, v := range b.datasetGroups {
+		res.DatasetGroups = append(res.DatasetGroups, ftypes.DatasetGroupSummary{
+			DatasetGroupArn:      aws.String(v.arn),
+			DatasetGroupName:     aws.String(v.name),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListDatasets(ctx context.Context, params *forecast.ListDatasetsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListDatasetsOutput{}
+	for _, v := range b.datasets {
+		res.Datasets = append(res.Datasets, ftypes.DatasetSummary{
+			DatasetArn:           aws.String(v.arn),
+			DatasetName:          aws.String(v.name),
+			DatasetType:          v.datasetType,
+			Domain:               v.domain,
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListDatasetImportJobs(ctx context.Context, params *forecast.ListDatasetImportJobsInput, optFns ...func(*forecast.Options)) (*forecast.ListDatasetImportJobsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListDatasetImportJobsOutput{}
+	for _, v := range b.datasetImportJobs {
+		res.DatasetImportJobs = append(res.DatasetImportJobs, ftypes.DatasetImportJobSummary{
+			DatasetImportJobArn:  aws.String(v.arn),
+			DatasetImportJobName: aws.String(v.name),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListPredictors(ctx context.Context, params *forecast.ListPredictorsInput, optFns ...func(*forecast.Options)) (*forecast.ListPredictorsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListPredictorsOutput{}
+	for _, v := range b.predictors {
+		res.Predictors = append(res.Predictors, ftypes.PredictorSummary{
+			PredictorArn:         aws.String(v.arn),
+			PredictorName:        aws.String(v.name),
+			DatasetGroupArn:      aws.String(v.datasetGroupArn),
+			IsAutoPredictor:      aws.Bool(v.auto),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListForecasts(ctx context.Context, params *forecast.ListForecastsInput, optFns ...func(*forecast.Options)) (*forecast.ListForecastsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListForecastsOutput{}
+	for _, v := range b.forecasts {
+		res.Forecasts = append(res.Forecasts, ftypes.ForecastSummary{
+			ForecastArn:          aws.String(v.arn),
+			ForecastName:         aws.String(v.name),
+			PredictorArn:         aws.String(v.predictorArn),
+			DatasetGroupArn:      aws.String(v.datasetGroupArn),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListForecastExportJobs(ctx context.Context, params *forecast.ListForecastExportJobsInput, optFns ...func(*forecast.Options)) (*forecast.ListForecastExportJobsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListForecastExportJobsOutput{}
+	for _, v := range b.forecastExportJobs {
+		res.ForecastExportJobs = append(res.ForecastExportJobs, ftypes.ForecastExportJobSummary{
+			ForecastExportJobArn:  aws.String(v.arn),
+			ForecastExportJobName: aws.String(v.name),
+			Status:                aws.String(v.status),
+			Message:               aws.String(v.message),
+			CreationTime:          aws.Time(v.created),
+			LastModificationTime:  aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListExplainabilities(ctx context.Context, params *forecast.ListExplainabilitiesInput, optFns ...func(*forecast.Options)) (*forecast.ListExplainabilitiesOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListExplainabilitiesOutput{}
+	for _, v := range b.explainabilities {
+		res.Explainabilities = append(res.Explainabilities, ftypes.ExplainabilitySummary{
+			ExplainabilityArn:    aws.String(v.arn),
+			ExplainabilityName:   aws.String(v.name),
+			ResourceArn:          aws.String(v.resourceArn),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListExplainabilityExports(ctx context.Context, params *forecast.ListExplainabilityExportsInput, optFns ...func(*forecast.Options)) (*forecast.ListExplainabilityExportsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListExplainabilityExportsOutput{}
+	for _, v := range b.explainExports {
+		res.ExplainabilityExports = append(res.ExplainabilityExports, ftypes.ExplainabilityExportSummary{
+			ExplainabilityExportArn:  aws.String(v.arn),
+			ExplainabilityExportName: aws.String(v.name),
+			Status:                   aws.String(v.status),
+			Message:                  aws.String(v.message),
+			CreationTime:             aws.Time(v.created),
+			LastModificationTime:     aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListWhatIfAnalyses(ctx context.Context, params *forecast.ListWhatIfAnalysesInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfAnalysesOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListWhatIfAnalysesOutput{}
+	for _, v := range b.whatIfAnalyses {
+		res.WhatIfAnalyses = append(res.WhatIfAnalyses, ftypes.WhatIfAnalysisSummary{
+			WhatIfAnalysisArn:    aws.String(v.arn),
+			WhatIfAnalysisName:   aws.String(v.name),
+			ForecastArn:          aws.String(v.forecastArn),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListWhatIfForecasts(ctx context.Context, params *forecast.ListWhatIfForecastsInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfForecastsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListWhatIfForecastsOutput{}
+	for _, v := range b.whatIfForecasts {
+		res.WhatIfForecasts = append(res.WhatIfForecasts, ftypes.WhatIfForecastSummary{
+			WhatIfForecastArn:    aws.String(v.arn),
+			WhatIfForecastName:   aws.String(v.name),
+			WhatIfAnalysisArn:    aws.String(v.whatIfAnalysisArn),
+			Status:               aws.String(v.status),
+			Message:              aws.String(v.message),
+			CreationTime:         aws.Time(v.created),
+			LastModificationTime: aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) ListWhatIfForecastExports(ctx context.Context, params *forecast.ListWhatIfForecastExportsInput, optFns ...func(*forecast.Options)) (*forecast.ListWhatIfForecastExportsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &forecast.ListWhatIfForecastExportsOutput{}
+	for _, v := range b.whatIfExports {
+		res.WhatIfForecastExports = append(res.WhatIfForecastExports, ftypes.WhatIfForecastExportSummary{
+			WhatIfForecastExportArn:  aws.String(v.arn),
+			WhatIfForecastExportName: aws.String(v.name),
+			WhatIfForecastArns:       v.whatIfForecastArns,
+			Status:                   aws.String(v.status),
+			Message:                  aws.String(v.message),
+			CreationTime:             aws.Time(v.created),
+			LastModificationTime:     aws.Time(v.modified),
+		})
+	}
+	return res, nil
+}
+
+func (b *localBackend) DescribeDatasetGroup(ctx context.Context, params *forecast.DescribeDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetGroupOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.datasetGroups[aws.ToString(params.DatasetGroupArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DatasetGroupArn))
+	}
+	return &forecast.DescribeDatasetGroupOutput{
+		DatasetGroupArn:      aws.String(v.arn),
+		DatasetGroupName:     aws.String(v.name),
+		DatasetArns:          v.datasetArns,
+		Domain:               v.domain,
+		Status:               aws.String(v.status),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeDataset(ctx context.Context, params *forecast.DescribeDatasetInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.datasets[aws.ToString(params.DatasetArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DatasetArn))
+	}
+	return &forecast.DescribeDatasetOutput{
+		DatasetArn:           aws.String(v.arn),
+		DatasetName:          aws.String(v.name),
+		DatasetType:          v.datasetType,
+		Domain:               v.domain,
+		DataFrequency:        aws.String(v.frequency),
+		Schema:               v.schema,
+		Status:               aws.String(v.status),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeDatasetImportJob(ctx context.Context, params *forecast.DescribeDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.DescribeDatasetImportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.datasetImportJobs[aws.ToString(params.DatasetImportJobArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DatasetImportJobArn))
+	}
+	return &forecast.DescribeDatasetImportJobOutput{
+		DatasetImportJobArn:  aws.String(v.arn),
+		DatasetImportJobName: aws.String(v.name),
+		DatasetArn:           aws.String(v.datasetArn),
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribePredictor(ctx context.Context, params *forecast.DescribePredictorInput, optFns ...func(*forecast.Options)) (*forecast.DescribePredictorOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.predictors[aws.ToString(params.PredictorArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.PredictorArn))
+	}
+	return &forecast.DescribePredictorOutput{
+		PredictorArn:         aws.String(v.arn),
+		PredictorName:        aws.String(v.name),
+		ForecastHorizon:      aws.Int32(int32(v.horizon)),
+		ForecastTypes:        v.forecastTypes,
+		AlgorithmArn:         aws.String(v.algorithmArn),
+		PerformAutoML:        aws.Bool(v.autoML),
+		EvaluationParameters: v.evaluation,
+		InputDataConfig:      &ftypes.InputDataConfig{DatasetGroupArn: aws.String(v.datasetGroupArn), SupplementaryFeatures: v.features},
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeAutoPredictor(ctx context.Context, params *forecast.DescribeAutoPredictorInput, optFns ...func(*forecast.Options)) (*forecast.DescribeAutoPredictorOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.predictors[aws.ToString(params.PredictorArn)]
+	if !ok || !v.auto {
+		return nil, notFound(aws.ToString(params.PredictorArn))
+	}
+	return &forecast.DescribeAutoPredictorOutput{
+		PredictorArn:         aws.String(v.arn),
+		PredictorName:        aws.String(v.name),
+		ForecastHorizon:      aws.Int32(int32(v.horizon)),
+		ForecastFrequency:    aws.String(v.frequency),
+		ForecastTypes:        v.forecastTypes,
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeForecast(ctx context.Context, params *forecast.DescribeForecastInput, optFns ...func(*forecast.Options)) (*forecast.DescribeForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.forecasts[aws.ToString(params.ForecastArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ForecastArn))
+	}
+	return &forecast.DescribeForecastOutput{
+		ForecastArn:          aws.String(v.arn),
+		ForecastName:         aws.String(v.name),
+		PredictorArn:         aws.String(v.predictorArn),
+		DatasetGroupArn:      aws.String(v.datasetGroupArn),
+		ForecastTypes:        v.forecastTypes,
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeForecastExportJob(ctx context.Context, params *forecast.DescribeForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.DescribeForecastExportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.forecastExportJobs[aws.ToString(params.ForecastExportJobArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ForecastExportJobArn))
+	}
+	return &forecast.DescribeForecastExportJobOutput{
+		ForecastExportJobArn:  aws.String(v.arn),
+		ForecastExportJobName: aws.String(v.name),
+		ForecastArn:           aws.String(v.forecastArn),
+		Status:                aws.String(v.status),
+		Message:               aws.String(v.message),
+		CreationTime:          aws.Time(v.created),
+		LastModificationTime:  aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeExplainability(ctx context.Context, params *forecast.DescribeExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.DescribeExplainabilityOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.explainabilities[aws.ToString(params.ExplainabilityArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ExplainabilityArn))
+	}
+	return &forecast.DescribeExplainabilityOutput{
+		ExplainabilityArn:    aws.String(v.arn),
+		ExplainabilityName:   aws.String(v.name),
+		ResourceArn:          aws.String(v.resourceArn),
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeExplainabilityExport(ctx context.Context, params *forecast.DescribeExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.DescribeExplainabilityExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.explainExports[aws.ToString(params.ExplainabilityExportArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.ExplainabilityExportArn))
+	}
+	return &forecast.DescribeExplainabilityExportOutput{
+		ExplainabilityExportArn:  aws.String(v.arn),
+		ExplainabilityExportName: aws.String(v.name),
+		ExplainabilityArn:        aws.String(v.explainabilityArn),
+		Status:                   aws.String(v.status),
+		Message:                  aws.String(v.message),
+		CreationTime:             aws.Time(v.created),
+		LastModificationTime:     aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeWhatIfAnalysis(ctx context.Context, params *forecast.DescribeWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfAnalysisOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.whatIfAnalyses[aws.ToString(params.WhatIfAnalysisArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.WhatIfAnalysisArn))
+	}
+	return &forecast.DescribeWhatIfAnalysisOutput{
+		WhatIfAnalysisArn:    aws.String(v.arn),
+		WhatIfAnalysisName:   aws.String(v.name),
+		ForecastArn:          aws.String(v.forecastArn),
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeWhatIfForecast(ctx context.Context, params *forecast.DescribeWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.whatIfForecasts[aws.ToString(params.WhatIfForecastArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.WhatIfForecastArn))
+	}
+	return &forecast.DescribeWhatIfForecastOutput{
+		WhatIfForecastArn:    aws.String(v.arn),
+		WhatIfForecastName:   aws.String(v.name),
+		WhatIfAnalysisArn:    aws.String(v.whatIfAnalysisArn),
+		ForecastTypes:        v.forecastTypes,
+		Status:               aws.String(v.status),
+		Message:              aws.String(v.message),
+		CreationTime:         aws.Time(v.created),
+		LastModificationTime: aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) DescribeWhatIfForecastExport(ctx context.Context, params *forecast.DescribeWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.DescribeWhatIfForecastExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.whatIfExports[aws.ToString(params.WhatIfForecastExportArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.WhatIfForecastExportArn))
+	}
+	return &forecast.DescribeWhatIfForecastExportOutput{
+		WhatIfForecastExportArn:  aws.String(v.arn),
+		WhatIfForecastExportName: aws.String(v.name),
+		WhatIfForecastArns:       v.whatIfForecastArns,
+		Status:                   aws.String(v.status),
+		Message:                  aws.String(v.message),
+		CreationTime:             aws.Time(v.created),
+		LastModificationTime:     aws.Time(v.modified),
+	}, nil
+}
+
+func (b *localBackend) UpdateDatasetGroup(ctx context.Context, params *forecast.UpdateDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.UpdateDatasetGroupOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dsg, ok := b.datasetGroups[aws.ToString(params.DatasetGroupArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.DatasetGroupArn))
+	}
+	for _, v := range params.DatasetArns {
+		if _, ok := b.datasets[v]; !ok {
+			return nil, notFound(v)
+		}
+	}
+	dsg.datasetArns = params.DatasetArns
+	dsg.modified = time.Now()
+	return &forecast.UpdateDatasetGroupOutput{}, nil
+}
+
+// GetAccuracyMetrics backtests the local model with the predictor's evaluation parameters.
+func (b *localBackend) GetAccuracyMetrics(ctx context.Context, params *forecast.GetAccuracyMetricsInput, optFns ...func(*forecast.Options)) (*forecast.GetAccuracyMetricsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.predictors[aws.ToString(params.PredictorArn)]
+	if !ok {
+		return nil, notFound(aws.ToString(params.PredictorArn))
+	}
+	if p.status != localStatusActive {
+		return nil, &ftypes.ResourceInUseException{Message: aws.String("Resource " + p.arn + " is not active.")}
+	}
+	ds, err := b.targetDataset(b.datasetGroups[p.datasetGroupArn])
+	if err != nil {
+		return nil, err
+	}
+	windows, offset := 1, p.horizon
+	if p.evaluation != nil {
+		if v := int(aws.ToInt32(p.evaluation.NumberOfBacktestWindows)); v > 0 {
+			windows = v
+		}
+		if v := int(aws.ToInt32(p.evaluation.BackTestWindowOffset)); v > 0 {
+			offset = v
+		}
+	}
+	summaries, err := localBacktest(ds, p, windows, offset)
+	if err != nil {
+		return nil, err
+	}
+	algorithmArn := p.algorithmArn
+	if len(algorithmArn) == 0 {
+		algorithmArn, _ = constant.AlgorithmArn("ETS")
+	}
+	return &forecast.GetAccuracyMetricsOutput{
+		PredictorEvaluationResults: []ftypes.EvaluationResult{
+			{
+				AlgorithmArn: aws.String(algorithmArn),
+				TestWindows:  summaries,
+			},
+		},
+	}, nil
+}
+
+// QueryForecast answers from the rows of a local forecast. The filter must name the item field, as
+// the Forecast Query service requires.
+func (b *localBackend) QueryForecast(ctx context.Context, params *forecastquery.QueryForecastInput, optFns ...func(*forecastquery.Options)) (*forecastquery.QueryForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.forecasts[aws.ToString(params.ForecastArn)]
+	if !ok {
+		return nil, &qtypes.ResourceNotFoundException{Message: aws.String("Resource " + aws.ToString(params.ForecastArn) + " not found.")}
+	}
+	if f.status != localStatusActive {
+		return nil, &qtypes.ResourceInUseException{Message: aws.String("Resource " + f.arn + " is not active.")}
+	}
+	itemId, ok := params.Filters[f.itemField]
+	if !ok {
+		return nil, &qtypes.InvalidInputException{Message: aws.String("Filters must include " + f.itemField + ".")}
+	}
+	predictions := make(map[string][]qtypes.DataPoint)
+	for _, row := range f.rows {
+		if row[0] != itemId {
+			continue
+		}
+		t, err := time.Parse(exportDateLayout, row[1])
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range f.forecastTypes {
+			value, err := strconv.ParseFloat(row[2 + i], 64)
+			if err != nil {
+				return nil, err
+			}
+			name := forecastTypeColumn(v)
+			predictions[name] = append(predictions[name], qtypes.DataPoint{Timestamp: aws.String(t.Format(queryDateLayout)), Value: aws.Float64(value)})
+		}
+	}
+	if len(predictions) == 0 {
+		return nil, &qtypes.ResourceNotFoundException{Message: aws.String("Item " + itemId + " not found.")}
+	}
+	return &forecastquery.QueryForecastOutput{Forecast: &qtypes.Forecast{Predictions: predictions}}, nil
+}
+
+// The Delete* methods refuse to delete a resource that another resource still depends on, as
+// Forecast does, so callers have to delete in dependency order.
+
+func (b *localBackend) DeleteDatasetGroup(ctx context.Context, params *forecast.DeleteDatasetGroupInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetGroupOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.DatasetGroupArn)
+	if _, ok := b.datasetGroups[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.predictors {
+		if v.datasetGroupArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.datasetGroups, arn)
+	return &forecast.DeleteDatasetGroupOutput{}, nil
+}
+
+func (b *localBackend) DeleteDataset(ctx context.Context, params *forecast.DeleteDatasetInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.DatasetArn)
+	if _, ok := b.datasets[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.datasetImportJobs {
+		if v.datasetArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.datasets, arn)
+	for _, v := range b.datasetGroups {
+		for i, w := range v.datasetArns {
+			if w == arn {
+				v.datasetArns = append(v.datasetArns[:i:i], v.datasetArns[i+1:]...)
+				break
+			}
+		}
+	}
+	return &forecast.DeleteDatasetOutput{}, nil
+}
+
+func (b *localBackend) DeleteDatasetImportJob(ctx context.Context, params *forecast.DeleteDatasetImportJobInput, optFns ...func(*forecast.Options)) (*forecast.DeleteDatasetImportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.DatasetImportJobArn)
+	if _, ok := b.datasetImportJobs[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	delete(b.datasetImportJobs, arn)
+	return &forecast.DeleteDatasetImportJobOutput{}, nil
+}
+
+func (b *localBackend) DeletePredictor(ctx context.Context, params *forecast.DeletePredictorInput, optFns ...func(*forecast.Options)) (*forecast.DeletePredictorOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.PredictorArn)
+	if _, ok := b.predictors[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.forecasts {
+		if v.predictorArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	for _, v := range b.explainabilities {
+		if v.resourceArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.predictors, arn)
+	return &forecast.DeletePredictorOutput{}, nil
+}
+
+func (b *localBackend) DeleteForecast(ctx context.Context, params *forecast.DeleteForecastInput, optFns ...func(*forecast.Options)) (*forecast.DeleteForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.ForecastArn)
+	if _, ok := b.forecasts[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.forecastExportJobs {
+		if v.forecastArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	for _, v := range b.whatIfAnalyses {
+		if v.forecastArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.forecasts, arn)
+	return &forecast.DeleteForecastOutput{}, nil
+}
+
+func (b *localBackend) DeleteForecastExportJob(ctx context.Context, params *forecast.DeleteForecastExportJobInput, optFns ...func(*forecast.Options)) (*forecast.DeleteForecastExportJobOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.ForecastExportJobArn)
+	if _, ok := b.forecastExportJobs[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	delete(b.forecastExportJobs, arn)
+	return &forecast.DeleteForecastExportJobOutput{}, nil
+}
+
+func (b *localBackend) DeleteExplainability(ctx context.Context, params *forecast.DeleteExplainabilityInput, optFns ...func(*forecast.Options)) (*forecast.DeleteExplainabilityOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.ExplainabilityArn)
+	if _, ok := b.explainabilities[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.explainExports {
+		if v.explainabilityArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.explainabilities, arn)
+	return &forecast.DeleteExplainabilityOutput{}, nil
+}
+
+func (b *localBackend) DeleteExplainabilityExport(ctx context.Context, params *forecast.DeleteExplainabilityExportInput, optFns ...func(*forecast.Options)) (*forecast.DeleteExplainabilityExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.ExplainabilityExportArn)
+	if _, ok := b.explainExports[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	delete(b.explainExports, arn)
+	return &forecast.DeleteExplainabilityExportOutput{}, nil
+}
+
+func (b *localBackend) DeleteWhatIfAnalysis(ctx context.Context, params *forecast.DeleteWhatIfAnalysisInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfAnalysisOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.WhatIfAnalysisArn)
+	if _, ok := b.whatIfAnalyses[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.whatIfForecasts {
+		if v.whatIfAnalysisArn == arn {
+			return nil, inUse(arn, v.arn)
+		}
+	}
+	delete(b.whatIfAnalyses, arn)
+	return &forecast.DeleteWhatIfAnalysisOutput{}, nil
+}
+
+func (b *localBackend) DeleteWhatIfForecast(ctx context.Context, params *forecast.DeleteWhatIfForecastInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfForecastOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.WhatIfForecastArn)
+	if _, ok := b.whatIfForecasts[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	for _, v := range b.whatIfExports {
+		for _, w := range v.whatIfForecastArns {
+			if w == arn {
+				return nil, inUse(arn, v.arn)
+			}
+		}
+	}
+	delete(b.whatIfForecasts, arn)
+	return &forecast.DeleteWhatIfForecastOutput{}, nil
+}
+
+func (b *localBackend) DeleteWhatIfForecastExport(ctx context.Context, params *forecast.DeleteWhatIfForecastExportInput, optFns ...func(*forecast.Options)) (*forecast.DeleteWhatIfForecastExportOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	arn := aws.ToString(params.WhatIfForecastExportArn)
+	if _, ok := b.whatIfExports[arn]; !ok {
+		return nil, notFound(arn)
+	}
+	delete(b.whatIfExports, arn)
+	return &forecast.DeleteWhatIfForecastExportOutput{}, nil
+}
+
+// targetDataset returns the imported TARGET_TIME_SERIES dataset of a dataset group.
+func (b *localBackend) targetDataset(dsg *localDatasetGroup)(*localDataset, error) {
+	if dsg == nil {
+		return nil, fmt.Errorf("Error: %s", "No DatasetGroup.")
+	}
+	for _, v := range dsg.datasetArns {
+		if ds, ok := b.datasets[v]; ok && ds.datasetType == ftypes.DatasetTypeTargetTimeSeries {
+			if len(ds.series) == 0 {
+				return nil, fmt.Errorf("Error: %s", "No Imported Data.")
+			}
+			return ds, nil
+		}
+	}
+	return nil, fmt.Errorf("Error: %s", "No Dataset.")
+}
+
+// relatedSeries returns the imported RELATED_TIME_SERIES of a dataset group, empty if it has none.
+func (b *localBackend) relatedSeries(dsg *localDatasetGroup) map[string]map[string]*localSeries {
+	for _, v := range dsg.datasetArns {
+		if ds, ok := b.datasets[v]; ok && ds.datasetType == ftypes.DatasetTypeRelatedTimeSeries && ds.related != nil {
+			return ds.related
+		}
+	}
+	return make(map[string]map[string]*localSeries)
+}
+
+// localSchemaFields returns the item, timestamp and target columns of a target time series schema:
+// the first string, timestamp and numeric attributes.
+func localSchemaFields(schema *ftypes.Schema)(string, string, string) {
+	item, timestamp, target := "", "", ""
+	if schema != nil {
+		for _, v := range schema.Attributes {
+			switch v.AttributeType {
+			case ftypes.AttributeTypeString :
+				if len(item) == 0 {
+					item = aws.ToString(v.AttributeName)
+				}
+			case ftypes.AttributeTypeTimestamp :
+				if len(timestamp) == 0 {
+					timestamp = aws.ToString(v.AttributeName)
+				}
+			case ftypes.AttributeTypeFloat, ftypes.AttributeTypeInteger :
+				if len(target) == 0 {
+					target = aws.ToString(v.AttributeName)
+				}
+			}
+		}
+	}
+	if len(item) == 0 {
+		item = "item_id"
+	}
+	if len(timestamp) == 0 {
+		timestamp = "timestamp"
+	}
+	if len(target) == 0 {
+		target = "target_value"
+	}
+	return item, timestamp, target
+}
+
+// parseLocalDataset reads a target time series CSV with the columns named by localSchemaFields.
+func parseLocalDataset(data []byte, schema *ftypes.Schema)(map[string]*localSeries, error) {
+	item, timestamp, target := localSchemaFields(schema)
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("Error: %s", "No Data.")
+	}
+	columns := make(map[string]int)
+	for i, v := range records[0] {
+		columns[v] = i
+	}
+	for _, v := range []string{item, timestamp, target} {
+		if _, ok := columns[v]; !ok {
+			return nil, fmt.Errorf("Error: %s", "No Column " + v + ".")
+		}
+	}
+	series := make(map[string]*localSeries)
+	for _, record := range records[1:] {
+		t, err := parseTimestamp(record[columns[timestamp]])
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseFloat(record[columns[target]], 64)
+		if err != nil {
+			return nil, err
+		}
+		itemId := record[columns[item]]
+		if _, ok := series[itemId]; !ok {
+			series[itemId] = &localSeries{}
+		}
+		series[itemId].timestamps = append(series[itemId].timestamps, t)
+		series[itemId].values = append(series[itemId].values, v)
+	}
+	return series, nil
+}
+
+// parseLocalRelated reads a related time series CSV into one series per item and numeric attribute.
+func parseLocalRelated(data []byte, schema *ftypes.Schema)(map[string]map[string]*localSeries, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("Error: %s", "No Schema.")
+	}
+	item, timestamp, _ := localSchemaFields(schema)
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	if len(records) > 0 {
+		for i, v := range records[0] {
+			columns[v] = i
+		}
+	}
+	for _, v := range []string{item, timestamp} {
+		if _, ok := columns[v]; !ok {
+			return nil, fmt.Errorf("Error: %s", "No Column " + v + ".")
+		}
+	}
+	attrs := []string{}
+	for _, v := range schema.Attributes {
+		if v.AttributeType == ftypes.AttributeTypeFloat || v.AttributeType == ftypes.AttributeTypeInteger {
+			if _, ok := columns[aws.ToString(v.AttributeName)]; ok {
+				attrs = append(attrs, aws.ToString(v.AttributeName))
+			}
+		}
+	}
+	related := make(map[string]map[string]*localSeries)
+	for _, record := range records[1:] {
+		t, err := parseTimestamp(record[columns[timestamp]])
+		if err != nil {
+			return nil, err
+		}
+		itemId := record[columns[item]]
+		if _, ok := related[itemId]; !ok {
+			related[itemId] = make(map[string]*localSeries)
+		}
+		for _, name := range attrs {
+			v, err := strconv.ParseFloat(record[columns[name]], 64)
+			if err != nil {
+				continue
+			}
+			if _, ok := related[itemId][name]; !ok {
+				related[itemId][name] = &localSeries{}
+			}
+			related[itemId][name].timestamps = append(related[itemId][name].timestamps, t)
+			related[itemId][name].values = append(related[itemId][name].values, v)
+		}
+	}
+	return related, nil
+}
+
+// predictLocal fits one model per item and returns export rows of item_id, date and one column per
+// forecast type.
+func predictLocal(ds *localDataset, p *localPredictor, forecastTypes []string)([][]string, error) {
+	frequency := p.frequency
+	if len(frequency) == 0 {
+		frequency = ds.frequency
+	}
+	itemIds := make([]string, 0, len(ds.series))
+	for k := range ds.series {
+		itemIds = append(itemIds, k)
+	}
+	sort.Strings(itemIds)
+	rows := [][]string{}
+	for _, itemId := range itemIds {
+		s := ds.series[itemId]
+		if len(s.values) <= p.horizon {
+			return nil, fmt.Errorf("Error: %s", "Data must be longer than Horizon.")
+		}
+		m := fitHoltWinters(s.values, seasonLengths[frequency])
+		last := s.timestamps[len(s.timestamps) - 1]
+		for h := 1; h <= p.horizon; h++ {
+			t, err := addFrequency(last, frequency, h)
+			if err != nil {
+				return nil, err
+			}
+			row := []string{itemId, t.UTC().Format(exportDateLayout)}
+			for _, v := range forecastTypes {
+				q := 0.5
+				if v != "mean" {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil {
+						return nil, err
+					}
+					q = f
+				}
+				row = append(row, strconv.FormatFloat(m.quantile(h, q), 'f', -1, 64))
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// localExportData writes forecast rows as an export file: the item field, date and one column per
+// forecast type.
+func localExportData(itemField string, forecastTypes []string, rows [][]string)([]byte, error) {
+	header := []string{itemField, "date"}
+	for _, v := range forecastTypes {
+		header = append(header, forecastTypeColumn(v))
+	}
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	w.Write(header)
+	w.WriteAll(rows)
+	return buf.Bytes(), w.Error()
+}
+
+// forecastTypeColumn names the export column of a forecast type the way Forecast does: 0.1 is p10.
+func forecastTypeColumn(forecastType string) string {
+	q, err := strconv.ParseFloat(forecastType, 64)
+	if err != nil {
+		return forecastType
+	}
+	// Round to two places so that 0.07 is p7 rather than p7.000000000000001.
+	return "p" + strconv.FormatFloat(math.Round(q * 10000) / 100, 'f', -1, 64)
+}
+
+func readLocalPath(ctx context.Context, path string)([]byte, error) {
+	rc, err := getBlobStore(ctx).Get(ctx, getStoreKey(ctx, path))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func writeLocalPath(ctx context.Context, path string, data []byte) error {
+	return getBlobStore(ctx).Put(ctx, getStoreKey(ctx, path), bytes.NewReader(data), "text/csv")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// TestLocalRun runs senddata, checkrun, getresult and deleterun against the local backend and the
+// file store, so the run and deletion stages are exercised without AWS.
+func TestLocalRun(t *testing.T) {
+	defer func(c ForecastBackend, s BlobStore) { forecastClient, blobStore = c, s }(forecastClient, blobStore)
+	forecastClient, blobStore = nil, nil
+	t.Setenv("FORECAST_BACKEND", backendLocal)
+	t.Setenv("STORE_TYPE", storeTypeFile)
+	t.Setenv("STORE_DIR", t.TempDir())
+	ctx := context.Background()
+
+	call := func(d map[string]string)(int, APIResponse) {
+		body, _ := json.Marshal(d)
+		res, err := HandleRequest(ctx, events.APIGatewayProxyRequest{Body: string(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r APIResponse
+		if err := json.Unmarshal([]byte(res.Body), &r); err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, r
+	}
+	exists := func(key string) error {
+		rc, err := getBlobStore(ctx).Get(ctx, key)
+		if err == nil {
+			rc.Close()
+		}
+		return err
+	}
+
+	series := map[string][]float64{}
+	for i := 0; i < 60; i++ {
+		v := 10 + float64(i % 7) + float64(i) / 10
+		series["a"] = append(series["a"], v)
+		series["b"] = append(series["b"], 2 * v)
+	}
+	data, _ := json.Marshal(series)
+	status, res := call(map[string]string{"action": "senddata", "data": string(data), "frequency": "D", "horizon": "5"})
+	if status != 200 {
+		t.Fatalf("senddata: %d %s", status, res.Message)
+	}
+	id := res.Message
+	if !isProgressId(id) {
+		t.Fatalf("senddata: invalid id %s", id)
+	}
+	if err := exists(getActiveRunKey(id)); err != nil {
+		t.Fatalf("senddata: run is not marked active: %v", err)
+	}
+
+	// checkrun
+	for i := 0; i < 10 && (res.Run == nil || res.Run.Status == runStatusRunning); i++ {
+		if status, res = call(map[string]string{"action": "checkrun", "id": id}); status != 200 {
+			t.Fatalf("checkrun: %d %s", status, res.Message)
+		}
+	}
+	if res.Run.Status != runStatusDone || res.Run.Stage != stageResult {
+		t.Fatalf("checkrun: got %s %s, want %s %s", res.Run.Stage, res.Run.Status, stageResult, runStatusDone)
+	}
+	for _, v := range stageOrder[:len(stageOrder) - 1] {
+		if _, ok := res.Run.StageTimes[v]; !ok {
+			t.Errorf("checkrun: stage %s has no time", v)
+		}
+	}
+	if err := exists(getActiveRunKey(id)); err != errBlobNotFound {
+		t.Errorf("checkrun: finished run is still marked active: %v", err)
+	}
+
+	// getresult
+	if status, res = call(map[string]string{"action": "getresult", "id": id}); status != 200 {
+		t.Fatalf("getresult: %d %s", status, res.Message)
+	}
+	if len(res.Result) != 2 {
+		t.Fatalf("getresult: got %d items, want 2", len(res.Result))
+	}
+	for itemId, rows := range res.Result {
+		if len(rows) != 5 {
+			t.Errorf("getresult: item %s has %d rows, want 5", itemId, len(rows))
+		}
+		for _, r := range rows {
+			if _, ok := r.Quantiles["p50"]; !ok {
+				t.Errorf("getresult: item %s %s has no p50", itemId, r.Date)
+			}
+		}
+	}
+
+	// deleterun, finished by checkrun as the scheduled event would
+	if status, res = call(map[string]string{"action": "deleterun", "id": id}); status != 200 {
+		t.Fatalf("deleterun: %d %s", status, res.Message)
+	}
+	for i := 0; i < 3 * len(deleteStageOrder) && res.Run.Status == runStatusDeleting; i++ {
+		if status, res = call(map[string]string{"action": "checkrun", "id": id}); status != 200 {
+			t.Fatalf("checkrun %d: %d %s", i, status, res.Message)
+		}
+	}
+	if res.Run.Status != runStatusDeleted {
+		t.Fatalf("deleterun: got %s %s, want %s", res.Run.Stage, res.Run.Status, runStatusDeleted)
+	}
+	if len(res.Run.Arns) != 0 {
+		t.Errorf("deleterun: resources left %v", res.Run.Arns)
+	}
+	if b := forecastClient.(*localBackend); len(b.datasetGroups) + len(b.datasets) + len(b.predictors) + len(b.forecasts) + len(b.forecastExportJobs) != 0 {
+		t.Error("deleterun: local backend still holds resources")
+	}
+	if keys, err := getBlobStore(ctx).List(ctx, getResultPrefix(id)); err != nil || len(keys) != 0 {
+		t.Errorf("deleterun: objects left %v %v", keys, err)
+	}
+	if err := exists(getDatasetKey(id, targetKind)); err != errBlobNotFound {
+		t.Errorf("deleterun: dataset object left: %v", err)
+	}
+	if err := exists(getActiveRunKey(id)); err != errBlobNotFound {
+		t.Errorf("deleterun: deleted run is still marked active: %v", err)
+	}
+	if status, res = call(map[string]string{"action": "getresult", "id": id}); status != 409 {
+		t.Errorf("getresult after deleterun: got %d %s, want 409", status, res.Message)
+	}
+	if status, res = call(map[string]string{"action": "deleterun", "id": id}); status != 200 || res.Run.Status != runStatusDeleted {
+		t.Errorf("deleterun again: got %d %s", status, res.Message)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"os"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const ruleReject  string = "reject"
+const ruleAllow   string = "allow"
+const ruleClip    string = "clip"
+const ruleFill    string = "fill"
+const ruleLinear  string = "linear"
+const ruleNone    string = "none"
+
+const problemNonFinite  string = "non_finite"
+const problemMissing    string = "missing"
+const problemNegative   string = "negative"
+const problemOutlier    string = "outlier"
+const problemVariance   string = "variance"
+
+const actionRejected  string = "rejected"
+const actionFilled    string = "filled"
+const actionClipped   string = "clipped"
+
+// CleanseRules decides what sendData does with bad points before anything is uploaded.
+//   NonFinite   reject | fill     NaN and Inf values
+//   Fill        linear | none     missing values and timestamp gaps
+//   Negative    allow | reject | clip
+//   Clip        clip values further than Clip MADs from the median, 0 to disable
+//   MinVariance reject a series whose variance is below MinVariance, 0 to disable
+type CleanseRules struct {
+	NonFinite   string
+	Fill        string
+	Negative    string
+	Clip        float64
+	MinVariance float64
+}
+
+// Problem is one point (Index -1 for the whole series) that a cleansing rule rejected or changed.
+type Problem struct {
+	ItemID    string   `json:"item_id"`
+	Index     int      `json:"index"`
+	Timestamp string   `json:"timestamp,omitempty"`
+	Rule      string   `json:"rule"`
+	Action    string   `json:"action"`
+	Value     *float64 `json:"value,omitempty"`
+}
+
+// getCleanseRules reads the rules from CLEANSE_* environment variables, overridden by the request's
+// nonfinite, fill, negative, clip and min_variance parameters.
+func getCleanseRules(d map[string]string)(*CleanseRules, error) {
+	rules := &CleanseRules{
+		NonFinite: ruleReject,
+		Fill:      ruleLinear,
+		Negative:  ruleAllow,
+	}
+	get := func(param string, env string) string {
+		if v, ok := d[param]; ok {
+			return v
+		}
+		return os.Getenv(env)
+	}
+	if v := get("nonfinite", "CLEANSE_NON_FINITE"); len(v) > 0 {
+		if v != ruleReject && v != ruleFill {
+			return nil, validationError("Invalid Cleansing Rule nonfinite.")
+		}
+		rules.NonFinite = v
+	}
+	if v := get("fill", "CLEANSE_FILL"); len(v) > 0 {
+		if v != ruleLinear && v != ruleNone {
+			return nil, validationError("Invalid Cleansing Rule fill.")
+		}
+		rules.Fill = v
+	}
+	if v := get("negative", "CLEANSE_NEGATIVE"); len(v) > 0 {
+		if v != ruleAllow && v != ruleReject && v != ruleClip {
+			return nil, validationError("Invalid Cleansing Rule negative.")
+		}
+		rules.Negative = v
+	}
+	if v := get("clip", "CLEANSE_CLIP"); len(v) > 0 {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, validationError("Invalid Cleansing Rule clip.")
+		}
+		rules.Clip = f
+	}
+	if v := get("min_variance", "CLEANSE_MIN_VARIANCE"); len(v) > 0 {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, validationError("Invalid Cleansing Rule min_variance.")
+		}
+		rules.MinVariance = f
+	}
+	return rules, nil
+}
+
+func newProblem(itemId string, i int, p Point, rule string, action string) Problem {
+	res := Problem{ItemID: itemId, Index: i, Timestamp: p.Timestamp, Rule: rule, Action: action}
+	if !p.Missing && isFinite(p.Value) {
+		v := p.Value
+		res.Value = &v
+	}
+	return res
+}
+
+// cleanseSeries applies rules to points in place and returns every problem it found. The series may
+// only be used when none of the problems was rejected.
+func cleanseSeries(itemId string, points []Point, rules *CleanseRules) []Problem {
+	problems := []Problem{}
+	missingRule := make(map[int]string)
+
+	// NaN / Inf
+	for i := range points {
+		if points[i].Missing || isFinite(points[i].Value) {
+			continue
+		}
+		if rules.NonFinite == ruleFill {
+			points[i].Missing = true
+			missingRule[i] = problemNonFinite
+		} else {
+			problems = append(problems, newProblem(itemId, i, points[i], problemNonFinite, actionRejected))
+		}
+	}
+
+	// Negative values
+	if rules.Negative != ruleAllow {
+		for i := range points {
+			if points[i].Missing || !isFinite(points[i].Value) || points[i].Value >= 0 {
+				continue
+			}
+			if rules.Negative == ruleClip {
+				problems = append(problems, newProblem(itemId, i, points[i], problemNegative, actionClipped))
+				points[i].Value = 0
+			} else {
+				problems = append(problems, newProblem(itemId, i, points[i], problemNegative, actionRejected))
+			}
+		}
+	}
+
+	// Missing values and gaps
+	known := []int{}
+	for i := range points {
+		if !points[i].Missing {
+			known = append(known, i)
+		}
+	}
+	for i := range points {
+		if !points[i].Missing {
+			continue
+		}
+		rule, ok := missingRule[i]
+		if !ok {
+			rule = problemMissing
+		}
+		if rules.Fill != ruleLinear || len(known) == 0 {
+			problems = append(problems, newProblem(itemId, i, points[i], rule, actionRejected))
+			continue
+		}
+		points[i].Value = interpolate(points, known, i)
+		points[i].Missing = false
+		problems = append(problems, newProblem(itemId, i, points[i], rule, actionFilled))
+	}
+	if hasRejected(problems) {
+		return problems
+	}
+
+	// Outliers
+	if rules.Clip > 0 {
+		values := make([]float64, len(points))
+		for i, p := range points {
+			values[i] = p.Value
+		}
+		m := median(values)
+		for i, v := range values {
+			values[i] = math.Abs(v - m)
+		}
+		// 1.4826 scales the MAD to a standard deviation for normal data.
+		mad := median(values) * 1.4826
+		if mad > 0 {
+			lo, hi := m - rules.Clip * mad, m + rules.Clip * mad
+			for i := range points {
+				if points[i].Value < lo || points[i].Value > hi {
+					problems = append(problems, newProblem(itemId, i, points[i], problemOutlier, actionClipped))
+					points[i].Value = math.Max(lo, math.Min(hi, points[i].Value))
+				}
+			}
+		}
+	}
+
+	// Variance
+	if rules.MinVariance > 0 && variance(points) < rules.MinVariance {
+		problems = append(problems, Problem{ItemID: itemId, Index: -1, Rule: problemVariance, Action: actionRejected})
+	}
+	return problems
+}
+
+// interpolate fills point i linearly from the nearest known points; edges take the nearest value.
+func interpolate(points []Point, known []int, i int) float64 {
+	j := sort.SearchInts(known, i)
+	if j == 0 {
+		return points[known[0]].Value
+	}
+	if j == len(known) {
+		return points[known[len(known) - 1]].Value
+	}
+	a, b := known[j - 1], known[j]
+	return points[a].Value + (points[b].Value - points[a].Value) * float64(i - a) / float64(b - a)
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func hasRejected(problems []Problem) bool {
+	for _, v := range problems {
+		if v.Action == actionRejected {
+			return true
+		}
+	}
+	return false
+}
+
+func median(values []float64) float64 {
+	s := append([]float64{}, values...)
+	sort.Float64s(s)
+	n := len(s)
+	if n == 0 {
+		return 0
+	}
+	if n % 2 == 1 {
+		return s[n / 2]
+	}
+	return (s[n / 2 - 1] + s[n / 2]) / 2
+}
+
+func variance(points []Point) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, p := range points {
+		mean += p.Value
+	}
+	mean /= float64(len(points))
+	v := 0.0
+	for _, p := range points {
+		v += (p.Value - mean) * (p.Value - mean)
+	}
+	return v / float64(len(points))
+}
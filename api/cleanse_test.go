@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCleanseSeries(t *testing.T) {
+	missing := Point{Missing: true}
+	tests := []struct {
+		name     string
+		points   []Point
+		rules    CleanseRules
+		want     []float64
+		problems []string
+		rejected bool
+	}{
+		{
+			name:   "clean",
+			points: []Point{{Value: 1}, {Value: 2}, {Value: 3}},
+			rules:  CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow},
+			want:   []float64{1, 2, 3},
+		},
+		{
+			name:     "interpolated",
+			points:   []Point{{Value: 1}, missing, missing, {Value: 4}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow},
+			want:     []float64{1, 2, 3, 4},
+			problems: []string{problemMissing + "/" + actionFilled, problemMissing + "/" + actionFilled},
+		},
+		{
+			name:     "edges",
+			points:   []Point{missing, {Value: 2}, {Value: 5}, missing},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow},
+			want:     []float64{2, 2, 5, 5},
+			problems: []string{problemMissing + "/" + actionFilled, problemMissing + "/" + actionFilled},
+		},
+		{
+			name:     "missing without fill",
+			points:   []Point{{Value: 1}, missing, {Value: 3}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleNone, Negative: ruleAllow},
+			problems: []string{problemMissing + "/" + actionRejected},
+			rejected: true,
+		},
+		{
+			name:     "all missing",
+			points:   []Point{missing, missing},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow},
+			problems: []string{problemMissing + "/" + actionRejected, problemMissing + "/" + actionRejected},
+			rejected: true,
+		},
+		{
+			name:     "non finite rejected",
+			points:   []Point{{Value: 1}, {Value: math.NaN()}, {Value: 3}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow},
+			problems: []string{problemNonFinite + "/" + actionRejected},
+			rejected: true,
+		},
+		{
+			name:     "non finite filled",
+			points:   []Point{{Value: 1}, {Value: math.Inf(1)}, {Value: 3}},
+			rules:    CleanseRules{NonFinite: ruleFill, Fill: ruleLinear, Negative: ruleAllow},
+			want:     []float64{1, 2, 3},
+			problems: []string{problemNonFinite + "/" + actionFilled},
+		},
+		{
+			name:     "negative clipped",
+			points:   []Point{{Value: 1}, {Value: -2}, {Value: 3}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleClip},
+			want:     []float64{1, 0, 3},
+			problems: []string{problemNegative + "/" + actionClipped},
+		},
+		{
+			name:     "negative rejected",
+			points:   []Point{{Value: 1}, {Value: -2}, {Value: 3}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleReject},
+			problems: []string{problemNegative + "/" + actionRejected},
+			rejected: true,
+		},
+		{
+			name:     "outlier clipped",
+			points:   []Point{{Value: 1}, {Value: 2}, {Value: 3}, {Value: 2}, {Value: 100}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow, Clip: 3},
+			want:     []float64{1, 2, 3, 2, 2 + 3 * 1.4826},
+			problems: []string{problemOutlier + "/" + actionClipped},
+		},
+		{
+			name:     "low variance",
+			points:   []Point{{Value: 5}, {Value: 5}, {Value: 5}},
+			rules:    CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow, MinVariance: 0.1},
+			problems: []string{problemVariance + "/" + actionRejected},
+			rejected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := tt.rules
+			problems := cleanseSeries("item", tt.points, &rules)
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("got problems %v, want %v", problems, tt.problems)
+			}
+			for i, p := range problems {
+				if got := p.Rule + "/" + p.Action; got != tt.problems[i] {
+					t.Errorf("problem %d: got %s, want %s", i, got, tt.problems[i])
+				}
+				if p.ItemID != "item" {
+					t.Errorf("problem %d: got item %s", i, p.ItemID)
+				}
+			}
+			if hasRejected(problems) != tt.rejected {
+				t.Fatalf("got rejected %v, want %v", hasRejected(problems), tt.rejected)
+			}
+			if tt.rejected {
+				return
+			}
+			for i, p := range tt.points {
+				if p.Missing || math.Abs(p.Value - tt.want[i]) > 1e-9 {
+					t.Errorf("point %d: got %v (missing %v), want %v", i, p.Value, p.Missing, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	points := []Point{{Value: 0}, {}, {}, {}, {Value: 8}, {}}
+	known := []int{0, 4}
+	tests := []struct {
+		i    int
+		want float64
+	}{
+		{1, 2},
+		{2, 4},
+		{3, 6},
+		{5, 8},
+	}
+	for _, tt := range tests {
+		if got := interpolate(points, known, tt.i); got != tt.want {
+			t.Errorf("interpolate(%d): got %v, want %v", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestGetCleanseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    CleanseRules
+		wantErr bool
+	}{
+		{"defaults", map[string]string{}, CleanseRules{NonFinite: ruleReject, Fill: ruleLinear, Negative: ruleAllow}, false},
+		{"overridden", map[string]string{"nonfinite": "fill", "fill": "none", "negative": "clip", "clip": "3.5", "min_variance": "0.5"}, CleanseRules{NonFinite: ruleFill, Fill: ruleNone, Negative: ruleClip, Clip: 3.5, MinVariance: 0.5}, false},
+		{"bad nonfinite", map[string]string{"nonfinite": "clip"}, CleanseRules{}, true},
+		{"bad fill", map[string]string{"fill": "spline"}, CleanseRules{}, true},
+		{"bad negative", map[string]string{"negative": "fill"}, CleanseRules{}, true},
+		{"negative clip", map[string]string{"clip": "-1"}, CleanseRules{}, true},
+		{"bad min_variance", map[string]string{"min_variance": "x"}, CleanseRules{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range []string{"CLEANSE_NON_FINITE", "CLEANSE_FILL", "CLEANSE_NEGATIVE", "CLEANSE_CLIP", "CLEANSE_MIN_VARIANCE"} {
+				t.Setenv(v, "")
+			}
+			rules, err := getCleanseRules(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *rules != tt.want {
+				t.Errorf("got %+v, want %+v", *rules, tt.want)
+			}
+		})
+	}
+}
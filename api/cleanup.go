@@ -0,0 +1,396 @@
+package main
+
+import (
+	"time"
+	"errors"
+	"strings"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+const stageDeleteExport        string = "deleteexport"
+const stageDeleteWhatIfExport  string = "deletewhatifexport"
+const stageDeleteScenario      string = "deletewhatifforecast"
+const stageDeleteWhatIf        string = "deletewhatifanalysis"
+const stageDeleteForecast      string = "deleteforecast"
+const stageDeleteExplainExport string = "deleteexplainabilityexport"
+const stageDeleteExplain       string = "deleteexplainability"
+const stageDeletePredictor     string = "deletepredictor"
+const stageDeleteImport        string = "deleteimport"
+const stageDeleteDataset       string = "deletedataset"
+const stageDeleteDatasetGroup  string = "deletedatasetgroup"
+const stageDeleteObjects       string = "deleteobjects"
+const runStatusDeleting        string = "DELETING"
+const runStatusDeleted         string = "DELETED"
+
+// deleteStageOrder tears a run down in dependency order. Each step returns true once its resource
+// is gone, so an interrupted deletion resumes at the first resource that still exists.
+var deleteStageOrder = []string{
+	stageDeleteExport,
+	stageDeleteWhatIfExport,
+	stageDeleteScenario,
+	stageDeleteWhatIf,
+	stageDeleteForecast,
+	stageDeleteExplainExport,
+	stageDeleteExplain,
+	stageDeletePredictor,
+	stageDeleteImport,
+	stageDeleteDataset,
+	stageDeleteDatasetGroup,
+	stageDeleteObjects,
+}
+
+var deleteSteps = map[string]func(context.Context, *Run)(bool, error){
+	stageDeleteExport:        deleteForecastExportJobStep,
+	stageDeleteWhatIfExport:  deleteWhatIfForecastExportStep,
+	stageDeleteScenario:      deleteWhatIfForecastStep,
+	stageDeleteWhatIf:        deleteWhatIfAnalysisStep,
+	stageDeleteForecast:      deleteForecastStep,
+	stageDeleteExplainExport: deleteExplainabilityExportStep,
+	stageDeleteExplain:       deleteExplainabilityStep,
+	stageDeletePredictor:     deletePredictorStep,
+	stageDeleteImport:        deleteDatasetImportJobStep,
+	stageDeleteDataset:       deleteDatasetStep,
+	stageDeleteDatasetGroup:  deleteDatasetGroupStep,
+	stageDeleteObjects:       deleteObjectsStep,
+}
+
+// deleteRun marks a registered run for deletion and deletes as much of it as can be deleted right
+// away. The scheduled event finishes the rest.
+func deleteRun(ctx context.Context, id string)(*Run, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := startDeletion(ctx, run); err != nil {
+		return run, err
+	}
+	return run, nil
+}
+
+func startDeletion(ctx context.Context, run *Run) error {
+	if run.Status == runStatusDeleted {
+		return nil
+	}
+	if run.Status != runStatusDeleting {
+		run.Status = runStatusDeleting
+		run.Stage = deleteStageOrder[0]
+	}
+	return advanceDeletion(ctx, run)
+}
+
+// advanceDeletion runs delete steps until one of them has to wait, and saves the new state.
+func advanceDeletion(ctx context.Context, run *Run) error {
+	for run.Status == runStatusDeleting {
+		step, ok := deleteSteps[run.Stage]
+		if !ok {
+			run.Stage = deleteStageOrder[0]
+			continue
+		}
+		done, err := step(ctx, run)
+		if err != nil {
+			saveDeletingRun(ctx, run)
+			return err
+		}
+		if !done {
+			break
+		}
+		run.StageTimes[run.Stage] = time.Now()
+		run.Stage = nextDeleteStage(run.Stage)
+		if len(run.Stage) < 1 {
+			run.Status = runStatusDeleted
+		}
+	}
+	return saveDeletingRun(ctx, run)
+}
+
+func nextDeleteStage(stage string) string {
+	for i, v := range deleteStageOrder {
+		if v == stage && i + 1 < len(deleteStageOrder) {
+			return deleteStageOrder[i + 1]
+		}
+	}
+	return ""
+}
+
+// isBusy reports whether a resource is in a state that Forecast will not delete it from yet.
+func isBusy(status string) bool {
+	return strings.HasSuffix(status, "_PENDING") || strings.HasSuffix(status, "_IN_PROGRESS") || strings.HasSuffix(status, "_STOPPING")
+}
+
+// deleteResource issues del for a resource that exists and is not busy. It reports done only once
+// the resource no longer exists, which the lookup of a later pass finds out.
+func deleteResource(run *Run, arnKey string, found bool, status string, del func() error)(bool, error) {
+	if !found {
+		delete(run.Arns, arnKey)
+		return true, nil
+	}
+	if isBusy(status) {
+		return false, nil
+	}
+	err := del()
+	var inUse *ftypes.ResourceInUseException
+	var missing *ftypes.ResourceNotFoundException
+	if errors.As(err, &inUse) {
+		return false, nil
+	} else if errors.As(err, &missing) {
+		delete(run.Arns, arnKey)
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func deleteForecastExportJobStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getForecastExportJob(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnForecastExportJob, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeleteForecastExportJobInput{
+			ForecastExportJobArn: res.ForecastExportJobArn,
+		}
+		_, err := forecastClient.DeleteForecastExportJob(ctx, input)
+		return err
+	})
+}
+
+// The what-if forecast and export steps cover every scenario recorded in the run.
+
+func deleteWhatIfForecastExportStep(ctx context.Context, run *Run)(bool, error) {
+	done := true
+	for i := range run.Scenarios {
+		s := &run.Scenarios[i]
+		res, found, err := getWhatIfForecastExport(ctx, run, s)
+		if err != nil {
+			return false, err
+		}
+		ok, err := deleteResource(run, s.arnKey(arnWhatIfExport), found, aws.ToString(res.Status), func() error {
+			input := &forecast.DeleteWhatIfForecastExportInput{
+				WhatIfForecastExportArn: res.WhatIfForecastExportArn,
+			}
+			_, err := forecastClient.DeleteWhatIfForecastExport(ctx, input)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		done = done && ok
+	}
+	return done, nil
+}
+
+func deleteWhatIfForecastStep(ctx context.Context, run *Run)(bool, error) {
+	done := true
+	for i := range run.Scenarios {
+		s := &run.Scenarios[i]
+		res, found, err := getWhatIfForecast(ctx, run, s)
+		if err != nil {
+			return false, err
+		}
+		ok, err := deleteResource(run, s.arnKey(arnWhatIfForecast), found, aws.ToString(res.Status), func() error {
+			input := &forecast.DeleteWhatIfForecastInput{
+				WhatIfForecastArn: res.WhatIfForecastArn,
+			}
+			_, err := forecastClient.DeleteWhatIfForecast(ctx, input)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		done = done && ok
+	}
+	return done, nil
+}
+
+func deleteWhatIfAnalysisStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getWhatIfAnalysis(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnWhatIf, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeleteWhatIfAnalysisInput{
+			WhatIfAnalysisArn: res.WhatIfAnalysisArn,
+		}
+		_, err := forecastClient.DeleteWhatIfAnalysis(ctx, input)
+		return err
+	})
+}
+
+func deleteForecastStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getForecast(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnForecast, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeleteForecastInput{
+			ForecastArn: res.ForecastArn,
+		}
+		_, err := forecastClient.DeleteForecast(ctx, input)
+		return err
+	})
+}
+
+func deleteExplainabilityExportStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getExplainabilityExport(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnExplainExport, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeleteExplainabilityExportInput{
+			ExplainabilityExportArn: res.ExplainabilityExportArn,
+		}
+		_, err := forecastClient.DeleteExplainabilityExport(ctx, input)
+		return err
+	})
+}
+
+func deleteExplainabilityStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getExplainability(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnExplain, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeleteExplainabilityInput{
+			ExplainabilityArn: res.ExplainabilityArn,
+		}
+		_, err := forecastClient.DeleteExplainability(ctx, input)
+		return err
+	})
+}
+
+func deletePredictorStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getPredictor(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	return deleteResource(run, arnPredictor, found, aws.ToString(res.Status), func() error {
+		input := &forecast.DeletePredictorInput{
+			PredictorArn: res.PredictorArn,
+		}
+		_, err := forecastClient.DeletePredictor(ctx, input)
+		return err
+	})
+}
+
+// The import job and dataset steps cover every dataset kind, whether or not the run recorded it.
+
+func deleteDatasetImportJobStep(ctx context.Context, run *Run)(bool, error) {
+	done := true
+	for _, kind := range datasetKinds {
+		res, found, err := getDatasetImportJob(ctx, run, kind)
+		if err != nil {
+			return false, err
+		}
+		ok, err := deleteResource(run, kind.ImportArnKey, found, aws.ToString(res.Status), func() error {
+			input := &forecast.DeleteDatasetImportJobInput{
+				DatasetImportJobArn: res.DatasetImportJobArn,
+			}
+			_, err := forecastClient.DeleteDatasetImportJob(ctx, input)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		done = done && ok
+	}
+	return done, nil
+}
+
+// The dataset and dataset group summaries carry no status, so those steps describe the resource.
+
+func deleteDatasetStep(ctx context.Context, run *Run)(bool, error) {
+	done := true
+	for _, kind := range datasetKinds {
+		res, found, err := getDataset(ctx, run, kind)
+		if err != nil {
+			return false, err
+		}
+		status := ""
+		if found {
+			input := &forecast.DescribeDatasetInput{
+				DatasetArn: res.DatasetArn,
+			}
+			d, err := forecastClient.DescribeDataset(ctx, input)
+			var missing *ftypes.ResourceNotFoundException
+			if errors.As(err, &missing) {
+				found = false
+			} else if err != nil {
+				return false, err
+			} else {
+				status = aws.ToString(d.Status)
+			}
+		}
+		ok, err := deleteResource(run, kind.ArnKey, found, status, func() error {
+			input := &forecast.DeleteDatasetInput{
+				DatasetArn: res.DatasetArn,
+			}
+			_, err := forecastClient.DeleteDataset(ctx, input)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		done = done && ok
+	}
+	return done, nil
+}
+
+func deleteDatasetGroupStep(ctx context.Context, run *Run)(bool, error) {
+	res, found, err := getDatasetGroup(ctx, run)
+	if err != nil {
+		return false, err
+	}
+	status := ""
+	if found {
+		input := &forecast.DescribeDatasetGroupInput{
+			DatasetGroupArn: res.DatasetGroupArn,
+		}
+		d, err := forecastClient.DescribeDatasetGroup(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			found = false
+		} else if err != nil {
+			return false, err
+		} else {
+			status = aws.ToString(d.Status)
+		}
+	}
+	return deleteResource(run, arnDatasetGroup, found, status, func() error {
+		input := &forecast.DeleteDatasetGroupInput{
+			DatasetGroupArn: res.DatasetGroupArn,
+		}
+		_, err := forecastClient.DeleteDatasetGroup(ctx, input)
+		return err
+	})
+}
+
+// deleteObjectsStep removes the uploaded datasets and the exported result, explainability and
+// scenarios. The run manifest is kept with status DELETED as a record of the teardown.
+func deleteObjectsStep(ctx context.Context, run *Run)(bool, error) {
+	store := getBlobStore(ctx)
+	keys, err := store.List(ctx, getResultPrefix(run.ID))
+	if err != nil {
+		return false, err
+	}
+	for _, prefix := range []string{getExplainabilityPrefix(run.ID), getWhatIfPrefix(run.ID)} {
+		v, err := store.List(ctx, prefix)
+		if err != nil {
+			return false, err
+		}
+		keys = append(keys, v...)
+	}
+	for _, kind := range datasetKinds {
+		keys = append(keys, getDatasetKey(run.ID, kind))
+	}
+	for _, v := range keys {
+		if err := store.Delete(ctx, v); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
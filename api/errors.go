@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"encoding/json"
+
+	"github.com/aws/smithy-go"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+const codeValidation string = "validation"
+const codeNotFound   string = "not_found"
+const codeConflict   string = "conflict"
+const codeThrottled  string = "throttled"
+const codeInternal   string = "internal"
+
+var errorStatusCodes = map[string]int{
+	codeValidation: http.StatusBadRequest,
+	codeNotFound:   http.StatusNotFound,
+	codeConflict:   http.StatusConflict,
+	codeThrottled:  http.StatusTooManyRequests,
+	codeInternal:   http.StatusInternalServerError,
+}
+
+// APIError is an error with a machine-readable code that decides the HTTP status of the response.
+type APIError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func (e *APIError) StatusCode() int {
+	if v, ok := errorStatusCodes[e.Code]; ok {
+		return v
+	}
+	return http.StatusInternalServerError
+}
+
+func validationError(message string) error {
+	return &APIError{Code: codeValidation, Message: "Error: " + message}
+}
+
+func notFoundError(message string) error {
+	return &APIError{Code: codeNotFound, Message: "Error: " + message}
+}
+
+func conflictError(message string) error {
+	return &APIError{Code: codeConflict, Message: "Error: " + message}
+}
+
+// toAPIError classifies err, including errors returned by Forecast and S3.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	code := codeInternal
+	var notFound *ftypes.ResourceNotFoundException
+	var exists *ftypes.ResourceAlreadyExistsException
+	var inUse *ftypes.ResourceInUseException
+	var invalidInput *ftypes.InvalidInputException
+	var limit *ftypes.LimitExceededException
+	var syntax *json.SyntaxError
+	var unmarshal *json.UnmarshalTypeError
+	var generic smithy.APIError
+	switch {
+	case errors.Is(err, errBlobNotFound), errors.As(err, &notFound):
+		code = codeNotFound
+	// LimitExceededException is an account quota, such as the number of predictors, that a retry
+	// will not lift.
+	case errors.As(err, &exists), errors.As(err, &inUse), errors.As(err, &limit):
+		code = codeConflict
+	case errors.As(err, &invalidInput), errors.As(err, &syntax), errors.As(err, &unmarshal):
+		code = codeValidation
+	case errors.As(err, &generic):
+		// Forecast Query errors share the Forecast error codes but not their types.
+		switch generic.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException", "SlowDown", "RequestLimitExceeded" :
+			code = codeThrottled
+		case "ResourceNotFoundException" :
+			code = codeNotFound
+		case "ResourceInUseException", "LimitExceededException" :
+			code = codeConflict
+		case "InvalidInputException", "InvalidNextTokenException" :
+			code = codeValidation
+		}
+	}
+	return &APIError{Code: code, Message: err.Error(), Err: err}
+}
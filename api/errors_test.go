@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"errors"
+	"net/http"
+	"testing"
+	"encoding/json"
+
+	"github.com/aws/smithy-go"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+func TestToAPIError(t *testing.T) {
+	var v int
+	syntax := json.Unmarshal([]byte("{"), &v)
+	unmarshal := json.Unmarshal([]byte(`"x"`), &v)
+	tests := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{"validation", validationError("Invalid Data."), codeValidation, http.StatusBadRequest},
+		{"not found", notFoundError("No Run."), codeNotFound, http.StatusNotFound},
+		{"conflict", conflictError("Run is deleted."), codeConflict, http.StatusConflict},
+		{"wrapped api error", fmt.Errorf("save: %w", conflictError("Run changed.")), codeConflict, http.StatusConflict},
+		{"blob not found", errBlobNotFound, codeNotFound, http.StatusNotFound},
+		{"wrapped blob not found", fmt.Errorf("get: %w", errBlobNotFound), codeNotFound, http.StatusNotFound},
+		{"resource not found", &ftypes.ResourceNotFoundException{}, codeNotFound, http.StatusNotFound},
+		{"already exists", &ftypes.ResourceAlreadyExistsException{}, codeConflict, http.StatusConflict},
+		{"in use", &ftypes.ResourceInUseException{}, codeConflict, http.StatusConflict},
+		{"limit exceeded", &ftypes.LimitExceededException{}, codeConflict, http.StatusConflict},
+		{"invalid input", &ftypes.InvalidInputException{}, codeValidation, http.StatusBadRequest},
+		{"json syntax", syntax, codeValidation, http.StatusBadRequest},
+		{"json type", unmarshal, codeValidation, http.StatusBadRequest},
+		{"throttling", &smithy.GenericAPIError{Code: "ThrottlingException"}, codeThrottled, http.StatusTooManyRequests},
+		{"slow down", &smithy.GenericAPIError{Code: "SlowDown"}, codeThrottled, http.StatusTooManyRequests},
+		{"query not found", &smithy.GenericAPIError{Code: "ResourceNotFoundException"}, codeNotFound, http.StatusNotFound},
+		{"query limit exceeded", &smithy.GenericAPIError{Code: "LimitExceededException"}, codeConflict, http.StatusConflict},
+		{"query invalid input", &smithy.GenericAPIError{Code: "InvalidInputException"}, codeValidation, http.StatusBadRequest},
+		{"unknown api error", &smithy.GenericAPIError{Code: "InternalServerError"}, codeInternal, http.StatusInternalServerError},
+		{"plain", errors.New("boom"), codeInternal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := toAPIError(tt.err)
+			if res.Code != tt.code {
+				t.Errorf("got code %s, want %s", res.Code, tt.code)
+			}
+			if res.StatusCode() != tt.status {
+				t.Errorf("got status %d, want %d", res.StatusCode(), tt.status)
+			}
+			// An APIError in the chain is returned as is; anything else is wrapped.
+			if !errors.Is(res, tt.err) && !errors.Is(tt.err, res) {
+				t.Errorf("%v is not related to %v", res, tt.err)
+			}
+		})
+	}
+}
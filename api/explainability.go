@@ -0,0 +1,326 @@
+package main
+
+import (
+	"io"
+	"os"
+	"log"
+	"math"
+	"sort"
+	"context"
+	"strconv"
+	"encoding/csv"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+// Impact is the normalized impact score of one attribute on the forecast, from -1 to 1.
+type Impact struct {
+	Attribute string  `json:"attribute"`
+	Impact    float64 `json:"impact"`
+}
+
+func createExplainability(ctx context.Context, id string, predictorArn string)(string, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	input := &forecast.CreateExplainabilityInput{
+		ExplainabilityName: aws.String(getForecastId(id)),
+		ResourceArn: aws.String(predictorArn),
+		ExplainabilityConfig: &ftypes.ExplainabilityConfig{
+			TimeSeriesGranularity: ftypes.TimeSeriesGranularityAll,
+			TimePointGranularity: ftypes.TimePointGranularityAll,
+		},
+	}
+	res, err := forecastClient.CreateExplainability(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.ExplainabilityArn), nil
+}
+
+func createExplainabilityExport(ctx context.Context, id string, explainabilityArn string, path string, roleArn string)(string, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	input := &forecast.CreateExplainabilityExportInput{
+		ExplainabilityExportName: aws.String(getForecastId(id)),
+		ExplainabilityArn: aws.String(explainabilityArn),
+		Destination: &ftypes.DataDestination{
+			S3Config: &ftypes.S3Config{
+				Path: aws.String(path),
+				RoleArn: aws.String(roleArn),
+			},
+		},
+	}
+	res, err := forecastClient.CreateExplainabilityExport(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.ExplainabilityExportArn), nil
+}
+
+// checkExplainability explains the predictor of a run once it is ACTIVE and exports the impact
+// scores. It reports the explainability status until that is ACTIVE, then the export status.
+func checkExplainability(ctx context.Context, run *Run)(string, error) {
+	id := run.ID
+	if !isAutoPredictor(run) {
+		return "", validationError("Run was not started with explain.")
+	}
+	// GetExplainability
+	res, found, err := getExplainability(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
+		// CreateExplainability
+		pre, found, err := getPredictor(ctx, run)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No Predictor.")
+		}
+		if aws.ToString(pre.Status) != "ACTIVE" {
+			return "", conflictError("Predictor is not active.")
+		}
+		arn, err := createExplainability(ctx, id, aws.ToString(pre.PredictorArn))
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		run.Arns[arnExplain] = arn
+		return "Start", nil
+	}
+	run.Arns[arnExplain] = aws.ToString(res.ExplainabilityArn)
+	if aws.ToString(res.Status) != "ACTIVE" {
+		return aws.ToString(res.Status), nil
+	}
+
+	// GetExplainabilityExport
+	exp, found, err := getExplainabilityExport(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
+		// CreateExplainabilityExport
+		path := getBlobStore(ctx).URI(getExplainabilityPrefix(id))
+		arn, err := createExplainabilityExport(ctx, id, aws.ToString(res.ExplainabilityArn), path, os.Getenv("FORECAST_ROLE_ARN"))
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		run.Arns[arnExplainExport] = arn
+		return "Start", nil
+	}
+	run.Arns[arnExplainExport] = aws.ToString(exp.ExplainabilityExportArn)
+	return aws.ToString(exp.Status), nil
+}
+
+// getImpacts reads the newest export of a run's explainability once it is ACTIVE and returns the
+// impact of each attribute, largest first.
+func getImpacts(ctx context.Context, id string)([]Impact, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return nil, conflictError("Run is deleted.")
+	}
+	exp, found, err := getExplainabilityExport(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, notFoundError("No Explainability.")
+	}
+	if aws.ToString(exp.Status) != "ACTIVE" {
+		return nil, conflictError("Explainability export is not active.")
+	}
+	exports, err := getObjectKeys(ctx, getExplainabilityPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(exports) == 0 {
+		return nil, notFoundError("No Explainability.")
+	}
+	schema, err := constant.GetSchema()
+	if err != nil {
+		return nil, err
+	}
+	store := getBlobStore(ctx)
+	impacts := make(map[string][]float64)
+	for _, key := range exports[0] {
+		rc, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		err = parseImpacts(rc, schema.ItemField(), impacts)
+		rc.Close()
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+	}
+	if len(impacts) == 0 {
+		return nil, notFoundError("No Explainability.")
+	}
+	res := []Impact{}
+	for k, v := range impacts {
+		sum := 0.0
+		for _, f := range v {
+			sum += f
+		}
+		res = append(res, Impact{Attribute: k, Impact: sum / float64(len(v))})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if math.Abs(res[i].Impact) != math.Abs(res[j].Impact) {
+			return math.Abs(res[i].Impact) > math.Abs(res[j].Impact)
+		}
+		return res[i].Attribute < res[j].Attribute
+	})
+	return res, nil
+}
+
+const impactAttributeColumn string = "attribute_name"
+const impactScoreColumn     string = "normalized_impact_score"
+
+// parseImpacts adds the scores of an export file to impacts. An export of all time series has one
+// row per attribute with attribute_name and normalized_impact_score columns. An export per time
+// series starts with the item field, and every other column is an attribute with one score per
+// item.
+func parseImpacts(in io.Reader, itemField string, impacts map[string][]float64) error {
+	records, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return nil
+	}
+	header := records[0]
+	nameColumn, scoreColumn := -1, -1
+	for i, v := range header {
+		switch v {
+		case impactAttributeColumn :
+			nameColumn = i
+		case impactScoreColumn :
+			scoreColumn = i
+		}
+	}
+	if nameColumn >= 0 && scoreColumn >= 0 {
+		for _, record := range records[1:] {
+			f, err := strconv.ParseFloat(record[scoreColumn], 64)
+			if err != nil {
+				return validationError("Invalid Impact Score " + record[scoreColumn] + ".")
+			}
+			impacts[record[nameColumn]] = append(impacts[record[nameColumn]], f)
+		}
+		return nil
+	}
+	if header[0] != itemField {
+		return validationError("Unknown Explainability Columns.")
+	}
+	for _, record := range records[1:] {
+		for i, v := range record[1:] {
+			if len(v) == 0 {
+				continue
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return validationError("Invalid Impact Score " + v + ".")
+			}
+			impacts[header[i + 1]] = append(impacts[header[i + 1]], f)
+		}
+	}
+	return nil
+}
+
+// Local explainability
+
+// localImpacts scores every related time series attribute by its correlation with the target on
+// the timestamps they share, averaged over items and scaled so the largest score is 1 or -1.
+func localImpacts(target map[string]*localSeries, related map[string]map[string]*localSeries) map[string]float64 {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for itemId, attrs := range related {
+		t, ok := target[itemId]
+		if !ok {
+			continue
+		}
+		values := make(map[int64]float64)
+		for i, v := range t.timestamps {
+			values[v.Unix()] = t.values[i]
+		}
+		for name, s := range attrs {
+			xs, ys := []float64{}, []float64{}
+			for i, v := range s.timestamps {
+				if y, ok := values[v.Unix()]; ok {
+					xs = append(xs, s.values[i])
+					ys = append(ys, y)
+				}
+			}
+			sums[name] += correlation(xs, ys)
+			counts[name]++
+		}
+	}
+	res := make(map[string]float64)
+	scale := 0.0
+	for k, v := range sums {
+		res[k] = v / float64(counts[k])
+		scale = math.Max(scale, math.Abs(res[k]))
+	}
+	if scale > 0 {
+		for k := range res {
+			res[k] /= scale
+		}
+	}
+	return res
+}
+
+// correlation is the Pearson correlation of xs and ys, 0 when either is constant.
+func correlation(xs []float64, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	mx, my := 0.0, 0.0
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	sxy, sxx, syy := 0.0, 0.0, 0.0
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx * syy)
+}
+
+// writeLocalImpacts writes impacts in the one row per attribute layout that parseImpacts reads.
+func writeLocalImpacts(out io.Writer, impacts map[string]float64) error {
+	names := []string{}
+	for k := range impacts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	w := csv.NewWriter(out)
+	w.Write([]string{impactAttributeColumn, impactScoreColumn})
+	for _, k := range names {
+		w.Write([]string{k, strconv.FormatFloat(impacts[k], 'f', -1, 64)})
+	}
+	w.Flush()
+	return w.Error()
+}
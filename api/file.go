@@ -0,0 +1,270 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"time"
+	"bytes"
+	"strconv"
+	"strings"
+	"encoding/csv"
+	"encoding/base64"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FileInfo describes how an uploaded file was read.
+type FileInfo struct {
+	Delimiter  string `json:"delimiter"`
+	DateFormat string `json:"date_format"`
+	Header     bool   `json:"header"`
+	Rows       int    `json:"rows"`
+	Items      int    `json:"items"`
+}
+
+var fileDelimiters = []rune{',', ';', '\t', '|'}
+
+// fileDateLayouts are tried in order; the first one that parses every timestamp of the file wins, so
+// day-first and month-first dates are told apart by the whole column. A column that both readings
+// parse needs the day_first parameter.
+var fileDateLayouts = []string{
+	layout4,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/1/2 15:04",
+	"2006/01/02",
+	"2006/1/2",
+	"01/02/2006 15:04",
+	"1/2/2006 15:04",
+	"01/02/2006",
+	"1/2/2006",
+	"02/01/2006 15:04",
+	"2/1/2006 15:04",
+	"02/01/2006",
+	"2/1/2006",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"20060102",
+	"2006-01",
+	"2006/01",
+}
+
+// getRequestFile returns the raw body of a file upload. API Gateway base64 encodes binary media types.
+func getRequestFile(request events.APIGatewayProxyRequest)([]byte, error) {
+	if !request.IsBase64Encoded {
+		return []byte(request.Body), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(request.Body)
+	if err != nil {
+		return nil, validationError("Invalid File Encoding.")
+	}
+	return b, nil
+}
+
+// detectDelimiter picks the candidate that splits the first lines into the same, largest number of fields.
+func detectDelimiter(data []byte) rune {
+	lines := strings.SplitN(string(data), "\n", 6)
+	if len(lines) > 5 {
+		lines = lines[:5]
+	}
+	best, bestFields := ',', 1
+	for _, d := range fileDelimiters {
+		n := -1
+		for _, l := range lines {
+			l = strings.TrimRight(l, "\r")
+			if len(l) == 0 {
+				continue
+			}
+			c := strings.Count(l, string(d)) + 1
+			if n < 0 {
+				n = c
+			} else if c != n {
+				n = 0
+				break
+			}
+		}
+		if n > bestFields {
+			best, bestFields = d, n
+		}
+	}
+	return best
+}
+
+var monthFirstLayouts = map[string]bool{"01/02/2006 15:04": true, "1/2/2006 15:04": true, "01/02/2006": true, "1/2/2006": true}
+var dayFirstLayouts = map[string]bool{"02/01/2006 15:04": true, "2/1/2006 15:04": true, "02/01/2006": true, "2/1/2006": true, "02.01.2006 15:04": true, "02.01.2006": true}
+
+// detectDateLayout finds the layout of a timestamp column. dayFirst is "true" or "false" to only
+// try day-first or month-first layouts, or empty to detect the order from the values.
+func detectDateLayout(values []string, dayFirst string)(string, error) {
+	if dayFirst != "" && dayFirst != "true" && dayFirst != "false" {
+		return "", validationError("Invalid Day First.")
+	}
+	parses := func(l string) bool {
+		for _, v := range values {
+			if _, err := time.Parse(l, v); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	for _, l := range fileDateLayouts {
+		if (dayFirst == "true" && monthFirstLayouts[l]) || (dayFirst == "false" && dayFirstLayouts[l]) {
+			continue
+		}
+		if !parses(l) {
+			continue
+		}
+		if dayFirst == "" && monthFirstLayouts[l] {
+			for k := range dayFirstLayouts {
+				if parses(k) {
+					return "", validationError("Ambiguous Date Format; set day_first to true or false.")
+				}
+			}
+		}
+		return l, nil
+	}
+	return "", validationError("Unknown Date Format.")
+}
+
+// resolveColumn finds a column by header name or by 0-based index.
+func resolveColumn(header []string, spec string, fallback int)(int, error) {
+	if len(spec) == 0 {
+		return fallback, nil
+	}
+	for i, v := range header {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(spec)) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(spec); err == nil && i >= 0 {
+		return i, nil
+	}
+	return -1, validationError("Invalid Column " + spec + ".")
+}
+
+// parseFileValue reads a number written with a decimal point, or with a decimal comma when the file is
+// not comma separated. An empty cell is a missing value.
+func parseFileValue(s string, delimiter rune)(Point, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return Point{Missing: true}, nil
+	}
+	if delimiter != ',' && strings.Contains(s, ",") && !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{Value: v}, nil
+}
+
+// parseFile turns a CSV or spreadsheet export into series keyed by item id. Columns are chosen with the
+// timestamp_column, value_column and item_id_column parameters and default to the first two columns
+// of a single item. day_first decides dates such as 01/02/2024 that read both ways.
+func parseFile(data []byte, d map[string]string)(map[string][]Point, *FileInfo, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	info := &FileInfo{}
+	delimiter := detectDelimiter(data)
+	info.Delimiter = string(delimiter)
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows := [][]string{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, validationError("Invalid File.")
+		}
+		if len(row) == 1 && len(strings.TrimSpace(row[0])) == 0 {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, nil, validationError("No Data.")
+	}
+
+	tc, err := resolveColumn(rows[0], d["timestamp_column"], 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	vc, err := resolveColumn(rows[0], d["value_column"], 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	ic := -1
+	if spec, ok := d["item_id_column"]; ok {
+		if ic, err = resolveColumn(rows[0], spec, -1); err != nil {
+			return nil, nil, err
+		}
+	}
+	width := tc
+	if vc > width {
+		width = vc
+	}
+	if ic > width {
+		width = ic
+	}
+
+	// The first row is a header unless its value cell is a number.
+	if len(rows[0]) > vc {
+		if _, err := parseFileValue(rows[0][vc], delimiter); err != nil {
+			info.Header = true
+			rows = rows[1:]
+		}
+	}
+	timestamps := make([]string, 0, len(rows))
+	for i, row := range rows {
+		if len(row) <= width {
+			return nil, nil, validationError("Missing Column at row " + strconv.Itoa(i + 1) + ".")
+		}
+		timestamps = append(timestamps, strings.TrimSpace(row[tc]))
+	}
+	dateLayout, err := detectDateLayout(timestamps, d["day_first"])
+	if err != nil {
+		return nil, nil, err
+	}
+	info.DateFormat = dateLayout
+
+	type filePoint struct {
+		t time.Time
+		p Point
+	}
+	items := make(map[string][]filePoint)
+	for i, row := range rows {
+		p, err := parseFileValue(row[vc], delimiter)
+		if err != nil {
+			return nil, nil, validationError("Invalid Value " + row[vc] + ".")
+		}
+		t, _ := time.Parse(dateLayout, timestamps[i])
+		p.Timestamp = t.Format(layout4)
+		itemId := defaultItemId
+		if ic >= 0 {
+			itemId = strings.TrimSpace(row[ic])
+		}
+		items[itemId] = append(items[itemId], filePoint{t: t, p: p})
+	}
+	series := make(map[string][]Point)
+	for itemId, v := range items {
+		sort.SliceStable(v, func(i, j int) bool { return v[i].t.Before(v[j].t) })
+		points := make([]Point, len(v))
+		for i := range v {
+			if i > 0 && v[i].t.Equal(v[i - 1].t) {
+				return nil, nil, validationError("Duplicate Timestamp " + v[i].p.Timestamp + ".")
+			}
+			points[i] = v[i].p
+		}
+		series[itemId] = points
+	}
+	info.Rows = len(rows)
+	info.Items = len(series)
+	return series, info, nil
+}
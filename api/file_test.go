@@ -0,0 +1,178 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want rune
+	}{
+		{"comma", "date,value\n2024-01-01,1\n", ','},
+		{"semicolon", "date;value\n2024-01-01;1,5\n", ';'},
+		{"tab", "date\tvalue\r\n2024-01-01\t1\r\n", '\t'},
+		{"pipe", "date|item|value\n2024-01-01|a|1\n", '|'},
+		{"single column", "value\n1\n2\n", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectDelimiter([]byte(tt.data)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDateLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		dayFirst string
+		want     string
+		wantErr  bool
+	}{
+		{"iso date", []string{"2024-01-01", "2024-01-02"}, "", "2006-01-02", false},
+		{"iso time", []string{"2024-01-01 00:00:00"}, "", layout4, false},
+		{"month first", []string{"01/13/2024", "01/14/2024"}, "", "01/02/2006", false},
+		{"day first", []string{"13/01/2024", "14/01/2024"}, "", "02/01/2006", false},
+		{"dotted", []string{"13.01.2024"}, "", "02.01.2006", false},
+		{"ambiguous", []string{"01/02/2024", "03/04/2024"}, "", "", true},
+		{"ambiguous day first", []string{"01/02/2024", "03/04/2024"}, "true", "02/01/2006", false},
+		{"ambiguous month first", []string{"01/02/2024", "03/04/2024"}, "false", "01/02/2006", false},
+		{"day first contradicted", []string{"01/13/2024"}, "true", "", true},
+		{"invalid day first", []string{"2024-01-01"}, "yes", "", true},
+		{"unknown", []string{"yesterday"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectDateLayout(tt.values, tt.dayFirst)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		params  map[string]string
+		want    map[string][]Point
+		info    FileInfo
+		wantErr bool
+	}{
+		{
+			name: "header",
+			data: "\xef\xbb\xbfdate,value\n2024-01-02,2\n2024-01-01,1\n",
+			want: map[string][]Point{
+				defaultItemId: {{Timestamp: "2024-01-01 00:00:00", Value: 1}, {Timestamp: "2024-01-02 00:00:00", Value: 2}},
+			},
+			info: FileInfo{Delimiter: ",", DateFormat: "2006-01-02", Header: true, Rows: 2, Items: 1},
+		},
+		{
+			name: "no header",
+			data: "2024-01-01,1\n\n2024-01-02,\n",
+			want: map[string][]Point{
+				defaultItemId: {{Timestamp: "2024-01-01 00:00:00", Value: 1}, {Timestamp: "2024-01-02 00:00:00", Missing: true}},
+			},
+			info: FileInfo{Delimiter: ",", DateFormat: "2006-01-02", Rows: 2, Items: 1},
+		},
+		{
+			name:   "decimal comma and named columns",
+			data:   "item;value;day\na;1,5;13.01.2024\nb;2,5;13.01.2024\na;3;14.01.2024\n",
+			params: map[string]string{"timestamp_column": "day", "value_column": "value", "item_id_column": "item"},
+			want: map[string][]Point{
+				"a": {{Timestamp: "2024-01-13 00:00:00", Value: 1.5}, {Timestamp: "2024-01-14 00:00:00", Value: 3}},
+				"b": {{Timestamp: "2024-01-13 00:00:00", Value: 2.5}},
+			},
+			info: FileInfo{Delimiter: ";", DateFormat: "02.01.2006", Header: true, Rows: 3, Items: 2},
+		},
+		{
+			name:   "day first",
+			data:   "date,value\n01/02/2024,1\n02/02/2024,2\n",
+			params: map[string]string{"day_first": "true"},
+			want: map[string][]Point{
+				defaultItemId: {{Timestamp: "2024-02-01 00:00:00", Value: 1}, {Timestamp: "2024-02-02 00:00:00", Value: 2}},
+			},
+			info: FileInfo{Delimiter: ",", DateFormat: "02/01/2006", Header: true, Rows: 2, Items: 1},
+		},
+		{
+			name:    "ambiguous",
+			data:    "date,value\n01/02/2024,1\n02/02/2024,2\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			data:    "2024-01-01,1\n2024-01-01,2\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing column",
+			data:    "date,value\n2024-01-01,1\n2024-01-02\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			data:    "date,value\n2024-01-01,1\n2024-01-02,x\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown column",
+			data:    "date,value\n2024-01-01,1\n",
+			params:  map[string]string{"value_column": "sales"},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			data:    "\n\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			if params == nil {
+				params = map[string]string{}
+			}
+			series, info, err := parseFile([]byte(tt.data), params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", series)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *info != tt.info {
+				t.Errorf("got info %+v, want %+v", *info, tt.info)
+			}
+			if len(series) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(series), len(tt.want))
+			}
+			for itemId, want := range tt.want {
+				got := series[itemId]
+				if len(got) != len(want) {
+					t.Fatalf("item %s: got %v, want %v", itemId, got, want)
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Errorf("item %s point %d: got %+v, want %+v", itemId, i, got[i], want[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+)
+
+// seasonLengths maps a Forecast frequency to the season length used by the local model.
+var seasonLengths = map[string]int{
+	"1min":  60,
+	"5min":  288,
+	"10min": 144,
+	"15min": 96,
+	"30min": 48,
+	"H":     24,
+	"D":     7,
+	"W":     52,
+	"M":     12,
+}
+
+var smoothingGrid = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+type holtWintersModel struct {
+	alpha    float64
+	beta     float64
+	gamma    float64
+	level    float64
+	trend    float64
+	seasonal []float64
+	sigma    float64
+	n        int
+}
+
+// fitHoltWinters fits additive Holt-Winters by grid search over the smoothing parameters. When the
+// series is shorter than two seasons it falls back to Holt's linear trend.
+func fitHoltWinters(values []float64, season int) *holtWintersModel {
+	if season < 2 || len(values) < 2 * season {
+		season = 0
+	}
+	gammas := smoothingGrid
+	if season == 0 {
+		gammas = []float64{0}
+	}
+	var best *holtWintersModel
+	bestSSE := math.Inf(1)
+	for _, alpha := range smoothingGrid {
+		for _, beta := range smoothingGrid {
+			for _, gamma := range gammas {
+				m, sse := runHoltWinters(values, season, alpha, beta, gamma)
+				if sse < bestSSE {
+					best, bestSSE = m, sse
+				}
+			}
+		}
+	}
+	return best
+}
+
+func runHoltWinters(values []float64, season int, alpha float64, beta float64, gamma float64)(*holtWintersModel, float64) {
+	m := &holtWintersModel{alpha: alpha, beta: beta, gamma: gamma, n: len(values)}
+	start := 1
+	if season > 0 {
+		first := mean(values[:season])
+		m.level = first
+		m.trend = (mean(values[season:2 * season]) - first) / float64(season)
+		m.seasonal = make([]float64, season)
+		for i := 0; i < season; i++ {
+			m.seasonal[i] = values[i] - first
+		}
+		start = season
+	} else {
+		m.level = values[0]
+		if len(values) > 1 {
+			m.trend = values[1] - values[0]
+		}
+	}
+	sse := 0.0
+	count := 0
+	for t := start; t < len(values); t++ {
+		s := 0.0
+		if season > 0 {
+			s = m.seasonal[t % season]
+		}
+		e := values[t] - (m.level + m.trend + s)
+		sse += e * e
+		count++
+		level := alpha * (values[t] - s) + (1 - alpha) * (m.level + m.trend)
+		m.trend = beta * (level - m.level) + (1 - beta) * m.trend
+		m.level = level
+		if season > 0 {
+			m.seasonal[t % season] = gamma * (values[t] - level) + (1 - gamma) * s
+		}
+	}
+	if count > 0 {
+		m.sigma = math.Sqrt(sse / float64(count))
+	}
+	return m, sse
+}
+
+// predict returns the point forecast h steps (1-based) after the end of the series.
+func (m *holtWintersModel) predict(h int) float64 {
+	s := 0.0
+	if len(m.seasonal) > 0 {
+		s = m.seasonal[(m.n + h - 1) % len(m.seasonal)]
+	}
+	return m.level + float64(h) * m.trend + s
+}
+
+// quantile assumes normally distributed errors that widen with the square root of the step.
+func (m *holtWintersModel) quantile(h int, q float64) float64 {
+	return m.predict(h) + math.Sqrt2 * math.Erfinv(2 * q - 1) * m.sigma * math.Sqrt(float64(h))
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
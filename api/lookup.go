@@ -0,0 +1,608 @@
+package main
+
+import (
+	"sync"
+	"errors"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+type lookupCacheKey struct{}
+
+// lookupCache keeps the resources found during one request or one scheduled run advance.
+type lookupCache struct {
+	mu    sync.Mutex
+	items map[string]interface{}
+}
+
+func withLookupCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lookupCacheKey{}, &lookupCache{items: make(map[string]interface{})})
+}
+
+func getCached(ctx context.Context, kind string, name string)(interface{}, bool) {
+	c, ok := ctx.Value(lookupCacheKey{}).(*lookupCache)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[kind + "/" + name]
+	return v, ok
+}
+
+func setCached(ctx context.Context, kind string, name string, v interface{}) {
+	c, ok := ctx.Value(lookupCacheKey{}).(*lookupCache)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[kind + "/" + name] = v
+}
+
+// The get* functions look a run's resource up by the ARN recorded in the run, and fall back to
+// searching every page of the List API by name for resources the run has not recorded yet. found is
+// false only when the resource does not exist; any other failure is returned as an error so that
+// callers never create a duplicate because of a throttled or denied lookup.
+
+func getDatasetGroup(ctx context.Context, run *Run)(ftypes.DatasetGroupSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnDatasetGroup, name); ok {
+		return v.(ftypes.DatasetGroupSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnDatasetGroup]; ok {
+		input := &forecast.DescribeDatasetGroupInput{
+			DatasetGroupArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeDatasetGroup(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.DatasetGroupSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.DatasetGroupSummary{}, false, err
+		}
+		v := ftypes.DatasetGroupSummary{
+			DatasetGroupArn:      res.DatasetGroupArn,
+			DatasetGroupName:     res.DatasetGroupName,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnDatasetGroup, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListDatasetGroupsInput{}
+	p := forecast.NewListDatasetGroupsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.DatasetGroupSummary{}, false, err
+		}
+		for _, v := range res.DatasetGroups {
+			if name == aws.ToString(v.DatasetGroupName) {
+				setCached(ctx, arnDatasetGroup, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.DatasetGroupSummary{}, false, nil
+}
+
+func getDataset(ctx context.Context, run *Run, kind datasetKind)(ftypes.DatasetSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := kind.name(run.ID)
+	if v, ok := getCached(ctx, kind.ArnKey, name); ok {
+		return v.(ftypes.DatasetSummary), true, nil
+	}
+	if arn, ok := run.Arns[kind.ArnKey]; ok {
+		input := &forecast.DescribeDatasetInput{
+			DatasetArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeDataset(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.DatasetSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.DatasetSummary{}, false, err
+		}
+		v := ftypes.DatasetSummary{
+			DatasetArn:           res.DatasetArn,
+			DatasetName:          res.DatasetName,
+			DatasetType:          res.DatasetType,
+			Domain:               res.Domain,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, kind.ArnKey, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListDatasetsInput{}
+	p := forecast.NewListDatasetsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.DatasetSummary{}, false, err
+		}
+		for _, v := range res.Datasets {
+			if name == aws.ToString(v.DatasetName) {
+				setCached(ctx, kind.ArnKey, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.DatasetSummary{}, false, nil
+}
+
+func getDatasetImportJob(ctx context.Context, run *Run, kind datasetKind)(ftypes.DatasetImportJobSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := kind.name(run.ID)
+	if v, ok := getCached(ctx, kind.ImportArnKey, name); ok {
+		return v.(ftypes.DatasetImportJobSummary), true, nil
+	}
+	if arn, ok := run.Arns[kind.ImportArnKey]; ok {
+		input := &forecast.DescribeDatasetImportJobInput{
+			DatasetImportJobArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeDatasetImportJob(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.DatasetImportJobSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.DatasetImportJobSummary{}, false, err
+		}
+		v := ftypes.DatasetImportJobSummary{
+			DatasetImportJobArn:  res.DatasetImportJobArn,
+			DatasetImportJobName: res.DatasetImportJobName,
+			DataSource:           res.DataSource,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, kind.ImportArnKey, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListDatasetImportJobsInput{}
+	p := forecast.NewListDatasetImportJobsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.DatasetImportJobSummary{}, false, err
+		}
+		for _, v := range res.DatasetImportJobs {
+			if name == aws.ToString(v.DatasetImportJobName) {
+				setCached(ctx, kind.ImportArnKey, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.DatasetImportJobSummary{}, false, nil
+}
+
+func getPredictor(ctx context.Context, run *Run)(ftypes.PredictorSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnPredictor, name); ok {
+		return v.(ftypes.PredictorSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnPredictor]; ok && isAutoPredictor(run) {
+		// DescribePredictor only describes predictors created with CreatePredictor.
+		input := &forecast.DescribeAutoPredictorInput{
+			PredictorArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeAutoPredictor(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.PredictorSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.PredictorSummary{}, false, err
+		}
+		v := ftypes.PredictorSummary{
+			PredictorArn:         res.PredictorArn,
+			PredictorName:        res.PredictorName,
+			IsAutoPredictor:      aws.Bool(true),
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnPredictor, name, v)
+		return v, true, nil
+	} else if ok {
+		input := &forecast.DescribePredictorInput{
+			PredictorArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribePredictor(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.PredictorSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.PredictorSummary{}, false, err
+		}
+		v := ftypes.PredictorSummary{
+			PredictorArn:         res.PredictorArn,
+			PredictorName:        res.PredictorName,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnPredictor, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListPredictorsInput{}
+	p := forecast.NewListPredictorsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.PredictorSummary{}, false, err
+		}
+		for _, v := range res.Predictors {
+			if name == aws.ToString(v.PredictorName) {
+				setCached(ctx, arnPredictor, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.PredictorSummary{}, false, nil
+}
+
+func getForecast(ctx context.Context, run *Run)(ftypes.ForecastSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnForecast, name); ok {
+		return v.(ftypes.ForecastSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnForecast]; ok {
+		input := &forecast.DescribeForecastInput{
+			ForecastArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeForecast(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.ForecastSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.ForecastSummary{}, false, err
+		}
+		v := ftypes.ForecastSummary{
+			ForecastArn:          res.ForecastArn,
+			ForecastName:         res.ForecastName,
+			PredictorArn:         res.PredictorArn,
+			DatasetGroupArn:      res.DatasetGroupArn,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnForecast, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListForecastsInput{}
+	p := forecast.NewListForecastsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.ForecastSummary{}, false, err
+		}
+		for _, v := range res.Forecasts {
+			if name == aws.ToString(v.ForecastName) {
+				setCached(ctx, arnForecast, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.ForecastSummary{}, false, nil
+}
+
+func getForecastExportJob(ctx context.Context, run *Run)(ftypes.ForecastExportJobSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnForecastExportJob, name); ok {
+		return v.(ftypes.ForecastExportJobSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnForecastExportJob]; ok {
+		input := &forecast.DescribeForecastExportJobInput{
+			ForecastExportJobArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeForecastExportJob(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.ForecastExportJobSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.ForecastExportJobSummary{}, false, err
+		}
+		v := ftypes.ForecastExportJobSummary{
+			ForecastExportJobArn:  res.ForecastExportJobArn,
+			ForecastExportJobName: res.ForecastExportJobName,
+			Destination:           res.Destination,
+			Status:                res.Status,
+			Message:               res.Message,
+			CreationTime:          res.CreationTime,
+			LastModificationTime:  res.LastModificationTime,
+		}
+		setCached(ctx, arnForecastExportJob, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListForecastExportJobsInput{}
+	p := forecast.NewListForecastExportJobsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.ForecastExportJobSummary{}, false, err
+		}
+		for _, v := range res.ForecastExportJobs {
+			if name == aws.ToString(v.ForecastExportJobName) {
+				setCached(ctx, arnForecastExportJob, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.ForecastExportJobSummary{}, false, nil
+}
+
+func getExplainability(ctx context.Context, run *Run)(ftypes.ExplainabilitySummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnExplain, name); ok {
+		return v.(ftypes.ExplainabilitySummary), true, nil
+	}
+	if arn, ok := run.Arns[arnExplain]; ok {
+		input := &forecast.DescribeExplainabilityInput{
+			ExplainabilityArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeExplainability(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.ExplainabilitySummary{}, false, nil
+		} else if err != nil {
+			return ftypes.ExplainabilitySummary{}, false, err
+		}
+		v := ftypes.ExplainabilitySummary{
+			ExplainabilityArn:    res.ExplainabilityArn,
+			ExplainabilityName:   res.ExplainabilityName,
+			ResourceArn:          res.ResourceArn,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnExplain, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListExplainabilitiesInput{}
+	p := forecast.NewListExplainabilitiesPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.ExplainabilitySummary{}, false, err
+		}
+		for _, v := range res.Explainabilities {
+			if name == aws.ToString(v.ExplainabilityName) {
+				setCached(ctx, arnExplain, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.ExplainabilitySummary{}, false, nil
+}
+
+func getExplainabilityExport(ctx context.Context, run *Run)(ftypes.ExplainabilityExportSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnExplainExport, name); ok {
+		return v.(ftypes.ExplainabilityExportSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnExplainExport]; ok {
+		input := &forecast.DescribeExplainabilityExportInput{
+			ExplainabilityExportArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeExplainabilityExport(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.ExplainabilityExportSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.ExplainabilityExportSummary{}, false, err
+		}
+		v := ftypes.ExplainabilityExportSummary{
+			ExplainabilityExportArn:  res.ExplainabilityExportArn,
+			ExplainabilityExportName: res.ExplainabilityExportName,
+			Destination:              res.Destination,
+			Status:                   res.Status,
+			Message:                  res.Message,
+			CreationTime:             res.CreationTime,
+			LastModificationTime:     res.LastModificationTime,
+		}
+		setCached(ctx, arnExplainExport, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListExplainabilityExportsInput{}
+	p := forecast.NewListExplainabilityExportsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.ExplainabilityExportSummary{}, false, err
+		}
+		for _, v := range res.ExplainabilityExports {
+			if name == aws.ToString(v.ExplainabilityExportName) {
+				setCached(ctx, arnExplainExport, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.ExplainabilityExportSummary{}, false, nil
+}
+
+func getWhatIfAnalysis(ctx context.Context, run *Run)(ftypes.WhatIfAnalysisSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getForecastId(run.ID)
+	if v, ok := getCached(ctx, arnWhatIf, name); ok {
+		return v.(ftypes.WhatIfAnalysisSummary), true, nil
+	}
+	if arn, ok := run.Arns[arnWhatIf]; ok {
+		input := &forecast.DescribeWhatIfAnalysisInput{
+			WhatIfAnalysisArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeWhatIfAnalysis(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.WhatIfAnalysisSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.WhatIfAnalysisSummary{}, false, err
+		}
+		v := ftypes.WhatIfAnalysisSummary{
+			WhatIfAnalysisArn:    res.WhatIfAnalysisArn,
+			WhatIfAnalysisName:   res.WhatIfAnalysisName,
+			ForecastArn:          res.ForecastArn,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnWhatIf, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListWhatIfAnalysesInput{}
+	p := forecast.NewListWhatIfAnalysesPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.WhatIfAnalysisSummary{}, false, err
+		}
+		for _, v := range res.WhatIfAnalyses {
+			if name == aws.ToString(v.WhatIfAnalysisName) {
+				setCached(ctx, arnWhatIf, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.WhatIfAnalysisSummary{}, false, nil
+}
+
+func getWhatIfForecast(ctx context.Context, run *Run, s *Scenario)(ftypes.WhatIfForecastSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getScenarioName(run.ID, s.Name)
+	if v, ok := getCached(ctx, arnWhatIfForecast, name); ok {
+		return v.(ftypes.WhatIfForecastSummary), true, nil
+	}
+	if arn, ok := run.Arns[s.arnKey(arnWhatIfForecast)]; ok {
+		input := &forecast.DescribeWhatIfForecastInput{
+			WhatIfForecastArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeWhatIfForecast(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.WhatIfForecastSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.WhatIfForecastSummary{}, false, err
+		}
+		v := ftypes.WhatIfForecastSummary{
+			WhatIfForecastArn:    res.WhatIfForecastArn,
+			WhatIfForecastName:   res.WhatIfForecastName,
+			WhatIfAnalysisArn:    res.WhatIfAnalysisArn,
+			Status:               res.Status,
+			Message:              res.Message,
+			CreationTime:         res.CreationTime,
+			LastModificationTime: res.LastModificationTime,
+		}
+		setCached(ctx, arnWhatIfForecast, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListWhatIfForecastsInput{}
+	p := forecast.NewListWhatIfForecastsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.WhatIfForecastSummary{}, false, err
+		}
+		for _, v := range res.WhatIfForecasts {
+			if name == aws.ToString(v.WhatIfForecastName) {
+				setCached(ctx, arnWhatIfForecast, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.WhatIfForecastSummary{}, false, nil
+}
+
+func getWhatIfForecastExport(ctx context.Context, run *Run, s *Scenario)(ftypes.WhatIfForecastExportSummary, bool, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	name := getScenarioName(run.ID, s.Name)
+	if v, ok := getCached(ctx, arnWhatIfExport, name); ok {
+		return v.(ftypes.WhatIfForecastExportSummary), true, nil
+	}
+	if arn, ok := run.Arns[s.arnKey(arnWhatIfExport)]; ok {
+		input := &forecast.DescribeWhatIfForecastExportInput{
+			WhatIfForecastExportArn: aws.String(arn),
+		}
+		res, err := forecastClient.DescribeWhatIfForecastExport(ctx, input)
+		var missing *ftypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return ftypes.WhatIfForecastExportSummary{}, false, nil
+		} else if err != nil {
+			return ftypes.WhatIfForecastExportSummary{}, false, err
+		}
+		v := ftypes.WhatIfForecastExportSummary{
+			WhatIfForecastExportArn:  res.WhatIfForecastExportArn,
+			WhatIfForecastExportName: res.WhatIfForecastExportName,
+			WhatIfForecastArns:       res.WhatIfForecastArns,
+			Destination:              res.Destination,
+			Status:                   res.Status,
+			Message:                  res.Message,
+			CreationTime:             res.CreationTime,
+			LastModificationTime:     res.LastModificationTime,
+		}
+		setCached(ctx, arnWhatIfExport, name, v)
+		return v, true, nil
+	}
+	input := &forecast.ListWhatIfForecastExportsInput{}
+	p := forecast.NewListWhatIfForecastExportsPaginator(forecastClient, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return ftypes.WhatIfForecastExportSummary{}, false, err
+		}
+		for _, v := range res.WhatIfForecastExports {
+			if name == aws.ToString(v.WhatIfForecastExportName) {
+				setCached(ctx, arnWhatIfExport, name, v)
+				return v, true, nil
+			}
+		}
+	}
+	return ftypes.WhatIfForecastExportSummary{}, false, nil
+}
@@ -1,17 +1,19 @@
 package main
 
 import (
+	"io"
 	"os"
-	"fmt"
 	"log"
+	"sort"
 	"time"
 	"bytes"
 	"strconv"
 	"strings"
 	"context"
-	"io/ioutil"
+	"encoding/csv"
 	"encoding/json"
 	"github.com/jszwec/csvutil"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 
@@ -20,103 +22,202 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/forecast"
 	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	stypes "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type APIResponse struct {
-	Message  string `json:"message"`
+	Message  string                  `json:"message"`
+	Code     string                  `json:"code,omitempty"`
+	Result   map[string][]ResultData `json:"result,omitempty"`
+	Run      *Run                    `json:"run,omitempty"`
+	Runs     []*Run                  `json:"runs,omitempty"`
+	Problems []Problem               `json:"problems,omitempty"`
+	File     *FileInfo               `json:"file,omitempty"`
+	Metrics  []Metrics               `json:"metrics,omitempty"`
+	Impacts  []Impact                `json:"impacts,omitempty"`
+	Scenario *ScenarioResult         `json:"scenario,omitempty"`
 }
 
 type ResultData struct {
-	ID   string  `csv:"item_id"`
-	Data string  `csv:"date"`
-	P10  float64 `csv:"p10"`
-	P50  float64 `csv:"p50"`
-	P90  float64 `csv:"p90"`
+	ItemID    string             `json:"item_id"`
+	Date      string             `json:"date"`
+	Quantiles map[string]float64 `json:"quantiles"`
+}
+
+type resultRecord struct {
+	ID   string `csv:"item_id"`
+	Date string `csv:"date"`
 }
 
 type Response events.APIGatewayProxyResponse
 
 var cfg aws.Config
 var s3Client *s3.Client
-var forecastClient *forecast.Client
+var forecastClient ForecastBackend
+var forecastQueryClient ForecastQueryBackend
 
 const layout              string = "2006-01-02 15:04"
 const layout2             string = "20060102150405.000"
 const layout3             string = "2006-01-02 00:00:00"
+const layout4             string = "2006-01-02 15:04:05"
 const idPrefix            string = "id"
 const bucketPath          string = "csv"
 const bucketResultPath    string = "result"
+const bucketRunPath       string = "runs"
+const bucketExplainPath   string = "explainability"
+const bucketWhatIfPath    string = "whatif"
+const bucketActivePath    string = "active"
+const defaultItemId       string = "v"
+const defaultFrequency    string = "D"
+const defaultHorizon      int    = 10
 
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (Response, error) {
+	ctx = withLookupCache(ctx)
 	var jsonBytes []byte
 	var err error
-	d := make(map[string]string)
-	json.Unmarshal([]byte(request.Body), &d)
-	if v, ok := d["action"]; ok {
+	var problems []Problem
+	d, e := getRequestParams(request)
+	if e != nil {
+		err = e
+	} else if v, ok := d["action"]; !ok {
+		err = validationError("No Action.")
+	} else {
+		id, hasId := d["id"]
 		switch v {
 		case "senddata" :
-			if data, ok := d["data"]; ok {
-				res, e := sendData(ctx, data)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
+			if data, ok := d["data"]; !ok {
+				err = validationError("No Data.")
+			} else if rules, e := getCleanseRules(d); e != nil {
+				err = e
+			} else if options, e := getPredictorOptions(d); e != nil {
+				err = e
+			} else if res, p, e := sendData(ctx, data, d["frequency"], d["horizon"], d["related"], d["metadata"], rules, options); e != nil {
+				problems = p
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res, Problems: p})
 			}
-		case "checkimport" :
-			if id, ok := d["id"]; ok {
-				res, e := checkImport(ctx, id)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
+		case "uploadfile" :
+			if file, e := getRequestFile(request); e != nil {
+				err = e
+			} else if series, info, e := parseFile(file, d); e != nil {
+				err = e
+			} else if rules, e := getCleanseRules(d); e != nil {
+				err = e
+			} else if options, e := getPredictorOptions(d); e != nil {
+				err = e
+			} else if res, p, e := startRun(ctx, series, nil, d["frequency"], d["horizon"], rules, options); e != nil {
+				problems = p
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res, Problems: p, File: info})
 			}
-		case "checkpredictor" :
-			if id, ok := d["id"]; ok {
-				res, e := checkPredictor(ctx, id)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
+		case "checkrun" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := advanceRun(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res.Status, Run: res})
 			}
-		case "checkforecast" :
-			if id, ok := d["id"]; ok {
-				res, e := checkForecast(ctx, id)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
-			}
-		case "checkexport" :
-			if id, ok := d["id"]; ok {
-				res, e := checkExport(ctx, id)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
+		case "checkimport", "checkpredictor", "checkforecast", "checkexport", "checkexplainability" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := checkStage(ctx, id, v); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res})
 			}
 		case "getresult" :
-			if id, ok := d["id"]; ok {
-				res, e := getResult(ctx, id)
-				if e != nil {
-					err = e
-				} else {
-					jsonBytes, _ = json.Marshal(APIResponse{Message: res})
-				}
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := getResult(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Result: res})
+			}
+		case "queryforecast" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := queryForecast(ctx, id, d["item_id"]); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Result: res})
+			}
+		case "getmetrics" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := getMetrics(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Metrics: res})
+			}
+		case "getexplainability" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := getImpacts(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Impacts: res})
+			}
+		case "createscenario" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if s, e := getScenario(d); e != nil {
+				err = e
+			} else if res, e := defineScenario(ctx, id, s); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res})
 			}
+		case "checkscenario" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := checkScenarioStage(ctx, id, d["name"]); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res})
+			}
+		case "getscenario" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := getScenarioResult(ctx, id, d["name"]); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Scenario: res})
+			}
+		case "listruns" :
+			if res, e := listRuns(ctx); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: "OK", Runs: res})
+			}
+		case "getrun" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := loadRun(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res.Status, Run: res})
+			}
+		case "deleterun" :
+			if !hasId {
+				err = validationError("No ID.")
+			} else if res, e := deleteRun(ctx, id); e != nil {
+				err = e
+			} else {
+				jsonBytes, _ = json.Marshal(APIResponse{Message: res.Status, Run: res})
+			}
+		default :
+			err = validationError("Unknown Action.")
 		}
 	}
 	log.Print(request.RequestContext.Identity.SourceIP)
 	if err != nil {
 		log.Print(err)
-		jsonBytes, _ = json.Marshal(APIResponse{Message: fmt.Sprint(err)})
+		apiErr := toAPIError(err)
+		jsonBytes, _ = json.Marshal(APIResponse{Message: apiErr.Message, Code: apiErr.Code, Problems: problems})
 		return Response{
-			StatusCode: 500,
+			StatusCode: apiErr.StatusCode(),
 			Body: string(jsonBytes),
 		}, nil
 	}
@@ -126,18 +227,33 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	}, nil
 }
 
+// getRequestParams reads the JSON body, or the query string for file uploads whose body is the file.
+func getRequestParams(request events.APIGatewayProxyRequest)(map[string]string, error) {
+	d := make(map[string]string)
+	if _, ok := request.QueryStringParameters["action"]; ok {
+		for k, v := range request.QueryStringParameters {
+			d[k] = v
+		}
+		return d, nil
+	}
+	if err := json.Unmarshal([]byte(request.Body), &d); err != nil {
+		return nil, validationError("Invalid Request Body.")
+	}
+	return d, nil
+}
+
 func getForecastId(id string) string {
 	return idPrefix + id
 }
 
-func createDatasetGroup(ctx context.Context, id string)(string, error) {
+func createDatasetGroup(ctx context.Context, id string, domain ftypes.Domain)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
 	input := &forecast.CreateDatasetGroupInput{
 		DatasetGroupName: aws.String(getForecastId(id)),
-		Domain: ftypes.DomainCustom,
+		Domain: domain,
 	}
 	res, err := forecastClient.CreateDatasetGroup(ctx, input)
 	if err != nil {
@@ -146,32 +262,20 @@ func createDatasetGroup(ctx context.Context, id string)(string, error) {
 	return aws.ToString(res.DatasetGroupArn), nil
 }
 
-func createDataset(ctx context.Context, id string)(string, error) {
+func createDataset(ctx context.Context, id string, frequency string, kind datasetKind, domain ftypes.Domain, schema *ftypes.Schema)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
 	input := &forecast.CreateDatasetInput{
-		DatasetName: aws.String(getForecastId(id)),
-		DataFrequency: aws.String("D"),
-		DatasetType: ftypes.DatasetTypeTargetTimeSeries,
-		Domain: ftypes.DomainCustom,
-		Schema: &ftypes.Schema{
-			Attributes: []ftypes.SchemaAttribute{
-				{
-					AttributeName: aws.String("item_id"),
-					AttributeType: ftypes.AttributeTypeString,
-				},
-				{
-					AttributeName: aws.String("timestamp"),
-					AttributeType: ftypes.AttributeTypeTimestamp,
-				},
-				{
-					AttributeName: aws.String("target_value"),
-					AttributeType: ftypes.AttributeTypeFloat,
-				},
-			},
-		},
+		DatasetName: aws.String(kind.name(id)),
+		DatasetType: kind.Type,
+		Domain: domain,
+		Schema: schema,
+	}
+	// Item metadata has no timestamps.
+	if kind.Type != ftypes.DatasetTypeItemMetadata {
+		input.DataFrequency = aws.String(frequency)
 	}
 	res, err := forecastClient.CreateDataset(ctx, input)
 	if err != nil {
@@ -180,13 +284,13 @@ func createDataset(ctx context.Context, id string)(string, error) {
 	return aws.ToString(res.DatasetArn), nil
 }
 
-func createDatasetImportJob(ctx context.Context, id string, datasetArn string, path string, roleArn string)(string, error) {
+func createDatasetImportJob(ctx context.Context, id string, kind datasetKind, datasetArn string, path string, roleArn string)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
 	input := &forecast.CreateDatasetImportJobInput{
-		DatasetImportJobName: aws.String(getForecastId(id)),
+		DatasetImportJobName: aws.String(kind.name(id)),
 		DatasetArn: aws.String(datasetArn),
 		DataSource: &ftypes.DataSource{
 			S3Config: &ftypes.S3Config{
@@ -202,7 +306,7 @@ func createDatasetImportJob(ctx context.Context, id string, datasetArn string, p
 	return aws.ToString(res.DatasetImportJobArn), nil
 }
 
-func createForecast(ctx context.Context, id string, predictorArn string)(string, error) {
+func createForecast(ctx context.Context, id string, predictorArn string, forecastTypes []string)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
@@ -211,6 +315,10 @@ func createForecast(ctx context.Context, id string, predictorArn string)(string,
 		ForecastName: aws.String(getForecastId(id)),
 		PredictorArn: aws.String(predictorArn),
 	}
+	// The forecast has to ask for the predictor's own forecast types when they are not the default.
+	if len(forecastTypes) > 0 {
+		input.ForecastTypes = forecastTypes
+	}
 	res, err := forecastClient.CreateForecast(ctx, input)
 	if err != nil {
 		return "", err
@@ -240,22 +348,22 @@ func createForecastExportJob(ctx context.Context, id string, forecastArn string,
 	return aws.ToString(res.ForecastExportJobArn), nil
 }
 
-func createPredictor(ctx context.Context, id string, datasetGroupArn string)(string, error) {
+func createPredictor(ctx context.Context, id string, datasetGroupArn string, frequency string, horizon int, options *PredictorOptions)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
 	input := &forecast.CreatePredictorInput{
 		PredictorName: aws.String(getForecastId(id)),
-		PerformAutoML: aws.Bool(true),
-		ForecastHorizon: aws.Int32(10),
+		ForecastHorizon: aws.Int32(int32(horizon)),
 		InputDataConfig: &ftypes.InputDataConfig{
 			DatasetGroupArn: aws.String(datasetGroupArn),
 		},
 		FeaturizationConfig: &ftypes.FeaturizationConfig{
-			ForecastFrequency: aws.String("D"),
+			ForecastFrequency: aws.String(frequency),
 		},
 	}
+	applyPredictorOptions(input, options)
 	res, err := forecastClient.CreatePredictor(ctx, input)
 	if err != nil {
 		return "", err
@@ -263,343 +371,598 @@ func createPredictor(ctx context.Context, id string, datasetGroupArn string)(str
 	return aws.ToString(res.PredictorArn), nil
 }
 
-func getDatasetGroup(ctx context.Context, id string) ftypes.DatasetGroupSummary {
+func createAutoPredictor(ctx context.Context, id string, datasetGroupArn string, frequency string, horizon int, options *PredictorOptions)(string, error) {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
-	input := &forecast.ListDatasetGroupsInput{}
-	res, err := forecastClient.ListDatasetGroups(ctx, input)
-	if err != nil {
-		return ftypes.DatasetGroupSummary{}
+	input := &forecast.CreateAutoPredictorInput{
+		PredictorName: aws.String(getForecastId(id)),
+		ForecastHorizon: aws.Int32(int32(horizon)),
+		ForecastFrequency: aws.String(frequency),
+		DataConfig: &ftypes.DataConfig{
+			DatasetGroupArn: aws.String(datasetGroupArn),
+		},
 	}
-	for _, v := range res.DatasetGroups {
-		if getForecastId(id) == aws.ToString(v.DatasetGroupName) {
-			return v
-		}
+	applyAutoPredictorOptions(input, options)
+	res, err := forecastClient.CreateAutoPredictor(ctx, input)
+	if err != nil {
+		return "", err
 	}
-	return ftypes.DatasetGroupSummary{}
+	return aws.ToString(res.PredictorArn), nil
 }
 
-func getDataset(ctx context.Context, id string) ftypes.DatasetSummary {
+func updateDatasetGroup(ctx context.Context, datasetArns []string, datasetGroupArn string) error {
 	if forecastClient == nil {
 		forecastClient = getForecastClient(ctx)
 	}
 
-	input := &forecast.ListDatasetsInput{}
-	res, err := forecastClient.ListDatasets(ctx, input)
-	if err != nil {
-		return ftypes.DatasetSummary{}
+	input := &forecast.UpdateDatasetGroupInput{
+		DatasetArns: datasetArns,
+		DatasetGroupArn: aws.String(datasetGroupArn),
 	}
-	for _, v := range res.Datasets {
-		if getForecastId(id) == aws.ToString(v.DatasetName) {
-			return v
-		}
+	_, err := forecastClient.UpdateDatasetGroup(ctx, input)
+	if err != nil {
+		return err
 	}
-	return ftypes.DatasetSummary{}
+	return nil
 }
 
-func getDatasetImportJob(ctx context.Context, id string) ftypes.DatasetImportJobSummary {
-	if forecastClient == nil {
-		forecastClient = getForecastClient(ctx)
-	}
-
-	input := &forecast.ListDatasetImportJobsInput{}
-	res, err := forecastClient.ListDatasetImportJobs(ctx, input)
+// getObjectKeys returns the part files of each export under prefix, newest export first and each
+// in part order. Forecast splits an export into <name>_<time>_part0.csv, _part1.csv, ... and a
+// run exported again keeps the files of the earlier export next to the new ones.
+func getObjectKeys(ctx context.Context, prefix string)([][]string, error) {
+	keys, err := getBlobStore(ctx).List(ctx, prefix)
 	if err != nil {
-		return ftypes.DatasetImportJobSummary{}
-	}
-	for _, v := range res.DatasetImportJobs {
-		if getForecastId(id) == aws.ToString(v.DatasetImportJobName) {
-			return v
+		return nil, err
+	}
+	parts := make(map[string]int)
+	exports := make(map[string][]string)
+	for _, v := range keys {
+		if stem, n, ok := getPartNumber(v); ok {
+			parts[v] = n
+			exports[stem] = append(exports[stem], v)
 		}
 	}
-	return ftypes.DatasetImportJobSummary{}
-}
-
-func getForecast(ctx context.Context, id string) ftypes.ForecastSummary {
-	if forecastClient == nil {
-		forecastClient = getForecastClient(ctx)
-	}
-
-	input := &forecast.ListForecastsInput{}
-	res, err := forecastClient.ListForecasts(ctx, input)
-	if err != nil {
-		return ftypes.ForecastSummary{}
+	stems := []string{}
+	for k := range exports {
+		stems = append(stems, k)
 	}
-	for _, v := range res.Forecasts {
-		if getForecastId(id) == aws.ToString(v.ForecastName) {
-			return v
-		}
+	// The time in the stem is fixed width, so the newest export sorts last.
+	sort.Sort(sort.Reverse(sort.StringSlice(stems)))
+	res := [][]string{}
+	for _, k := range stems {
+		v := exports[k]
+		sort.Slice(v, func(i, j int) bool { return parts[v[i]] < parts[v[j]] })
+		res = append(res, v)
 	}
-	return ftypes.ForecastSummary{}
+	return res, nil
 }
 
-func getForecastExportJob(ctx context.Context, id string) ftypes.ForecastExportJobSummary {
-	if forecastClient == nil {
-		forecastClient = getForecastClient(ctx)
+// getPartNumber splits a part file key into its <name>_<time> stem and part number.
+func getPartNumber(key string)(string, int, bool) {
+	if !strings.HasSuffix(key, ".csv") {
+		return "", 0, false
 	}
-
-	input := &forecast.ListForecastExportJobsInput{}
-	res, err := forecastClient.ListForecastExportJobs(ctx, input)
-	if err != nil {
-		return ftypes.ForecastExportJobSummary{}
+	i := strings.LastIndex(key, "_part")
+	if i < 0 {
+		return "", 0, false
 	}
-	for _, v := range res.ForecastExportJobs {
-		if getForecastId(id) == aws.ToString(v.ForecastExportJobName) {
-			return v
-		}
+	n, err := strconv.Atoi(strings.TrimSuffix(key[i + len("_part"):], ".csv"))
+	if err != nil || n < 0 {
+		return "", 0, false
 	}
-	return ftypes.ForecastExportJobSummary{}
+	return key[:i], n, true
 }
 
-func getPredictor(ctx context.Context, id string) ftypes.PredictorSummary {
-	if forecastClient == nil {
-		forecastClient = getForecastClient(ctx)
+// writeDataset writes the series as the TARGET_TIME_SERIES CSV with the columns in schema order.
+func writeDataset(out io.Writer, series map[string][]Point, schema *constant.Schema) error {
+	w := csv.NewWriter(out)
+	header := make([]string, len(schema.Attributes))
+	for i, v := range schema.Attributes {
+		header[i] = v.Name
+	}
+	w.Write(header)
+	for _, itemId := range sortedItemIds(series) {
+		for _, v := range series[itemId] {
+			row := make([]string, len(header))
+			for i, name := range header {
+				switch name {
+				case schema.ItemField() :
+					row[i] = itemId
+				case constant.TimestampField :
+					row[i] = v.Timestamp
+				default :
+					row[i] = strconv.FormatFloat(v.Value, 'f', -1, 64)
+				}
+			}
+			w.Write(row)
+		}
 	}
+	w.Flush()
+	return w.Error()
+}
 
-	input := &forecast.ListPredictorsInput{}
-	res, err := forecastClient.ListPredictors(ctx, input)
-	if err != nil {
-		return ftypes.PredictorSummary{}
-	}
-	for _, v := range res.Predictors {
-		if getForecastId(id) == aws.ToString(v.PredictorName) {
-			return v
+// uploadData builds small datasets in memory. Datasets with more than streamPoints rows are written
+// through a pipe so that the store uploads them in parts while the CSV is generated.
+func uploadData(ctx context.Context, key string, write func(io.Writer) error, rows int, streamPoints int) error {
+	contentType := "text/csv"
+	if rows <= streamPoints {
+		buf := new(bytes.Buffer)
+		if err := write(buf); err != nil {
+			log.Print(err)
+			return err
 		}
+		err := getBlobStore(ctx).Put(ctx, key, bytes.NewReader(buf.Bytes()), contentType)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		return nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	err := getBlobStore(ctx).Put(ctx, key, pr, contentType)
+	pr.CloseWithError(err)
+	if err != nil {
+		log.Print(err)
+		return err
 	}
-	return ftypes.PredictorSummary{}
+	return nil
 }
 
-func updateDatasetGroup(ctx context.Context, datasetArn string, datasetGroupArn string) error {
-	if forecastClient == nil {
-		forecastClient = getForecastClient(ctx)
+func sendData(ctx context.Context, data string, frequency string, horizon string, related string, metadata string, rules *CleanseRules, options *PredictorOptions)(string, []Problem, error) {
+	series, err := parseSeries(data)
+	if err != nil {
+		log.Print(err)
+		return "", nil, validationError("Invalid Data.")
 	}
-
-	input := &forecast.UpdateDatasetGroupInput{
-		DatasetArns: []string{datasetArn},
-		DatasetGroupArn: aws.String(datasetGroupArn),
+	schema, err := constant.GetSchema()
+	if err != nil {
+		log.Print(err)
+		return "", nil, err
 	}
-	_, err := forecastClient.UpdateDatasetGroup(ctx, input)
+	c, err := parseCovariates(related, metadata, schema)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	return nil
+	return startRun(ctx, series, c, frequency, horizon, rules, options)
 }
 
-func getObjectKey(ctx context.Context, id string) string {
-	if s3Client == nil {
-		s3Client = getS3Client(ctx)
+// startRun validates and cleanses the series, uploads them with any covariates and starts a run.
+// Problems found by cleansing are returned even when the data is accepted.
+func startRun(ctx context.Context, series map[string][]Point, c *Covariates, frequency string, horizon string, rules *CleanseRules, options *PredictorOptions)(string, []Problem, error) {
+	limits, err := constant.GetLimits()
+	if err != nil {
+		log.Print(err)
+		return "", nil, err
 	}
-
-	input := &s3.ListObjectsInput{
-		Bucket: aws.String(os.Getenv("BUCKET_NAME")),
+	schema, err := constant.GetSchema()
+	if err != nil {
+		log.Print(err)
+		return "", nil, err
 	}
-	res, err := s3Client.ListObjects(ctx, input)
+	domain := ftypes.Domain(schema.Domain)
+	run, err := newRun(frequency, horizon)
 	if err != nil {
-		return ""
+		return "", nil, err
 	}
-	for _, v := range res.Contents {
-		if strings.HasPrefix(aws.ToString(v.Key), bucketResultPath + "/" + getForecastId(id)) && strings.HasSuffix(aws.ToString(v.Key), "part0.csv") {
-			return aws.ToString(v.Key)
-		}
+	if len(series) == 0 {
+		return "", nil, validationError("No Data.")
 	}
-	return ""
-}
-
-func uploadData(ctx context.Context, id string, values []float64) error {
 	t := time.Now()
-	stringData := "item_id,timestamp,target_value\n"
-	contentType := "text/csv"
-	filename := getForecastId(id) + ".csv"
-	for i, v := range values {
-		t_ := t.AddDate(0, 0, i - len(values))
-		stringData += "v," + t_.Format(layout3) + "," + strconv.FormatFloat(v, 'f', -1, 64) + "\n"
+	problems := []Problem{}
+	rows := 0
+	minLength := 0
+	for _, itemId := range sortedItemIds(series) {
+		if len(itemId) == 0 {
+			return "", nil, validationError("Invalid Item ID.")
+		}
+		points, err := normalizeTimestamps(series[itemId], run.Frequency, t, limits.MaxPoints)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(points) < limits.MinPointsFor(run.Frequency, run.Horizon) || len(points) > limits.MaxPoints {
+			return "", nil, validationError("Invalid Data Size.")
+		}
+		if len(points) <= run.Horizon {
+			return "", nil, validationError("Data must be longer than Horizon.")
+		}
+		problems = append(problems, cleanseSeries(itemId, points, rules)...)
+		series[itemId] = points
+		rows += len(points)
+		if minLength == 0 || len(points) < minLength {
+			minLength = len(points)
+		}
 	}
-	if s3Client == nil {
-		s3Client = getS3Client(ctx)
+	if hasRejected(problems) {
+		return "", problems, validationError("Invalid Data Values.")
 	}
-	input := &s3.PutObjectInput{
-		ACL: stypes.ObjectCannedACLPrivate,
-		Bucket: aws.String(os.Getenv("BUCKET_NAME")),
-		Key: aws.String(bucketPath + "/" + filename),
-		Body: bytes.NewReader([]byte(stringData)),
-		ContentType: aws.String(contentType),
+	if err := options.checkBacktest(run.Horizon, minLength); err != nil {
+		return "", problems, err
 	}
-	_, err := s3Client.PutObject(ctx, input)
-	if err != nil {
-		log.Print(err)
-		return err
+	if c == nil {
+		c = &Covariates{}
 	}
-	return nil
-}
+	if err := alignCovariates(c, series, run.Frequency, run.Horizon); err != nil {
+		return "", problems, err
+	}
+	progressId := t.Format(layout2)[:14] + t.Format(layout2)[15:]
+	run.ID = progressId
+	run.Items = sortedItemIds(series)
+	run.Stage = stageImport
+	run.Status = runStatusRunning
+	run.CreatedAt = t
+	run.Datasets = []string{string(targetKind.Type)}
+	run.Predictor = options
+	schemas := map[ftypes.DatasetType]*ftypes.Schema{targetKind.Type: targetSchema(schema)}
 
-func sendData(ctx context.Context, data string)(string, error) {
-	mx := 100
-	mn := 30
-	var values []float64
-	if err := json.Unmarshal([]byte(data), &values); err != nil {
+	// Upload Data
+	err = uploadData(ctx, getDatasetKey(progressId, targetKind), func(w io.Writer) error { return writeDataset(w, series, schema) }, rows, limits.StreamPoints)
+	if err != nil {
 		log.Print(err)
-		return "", err
+		return "", nil, err
 	}
-	if len(values) < mn || len(values) > mx {
-		return "", fmt.Errorf("Error: %s", "Invalid Data Size.")
+	if len(c.Related) > 0 {
+		relatedRows := 0
+		for _, points := range c.Related {
+			relatedRows += len(points)
+		}
+		err = uploadData(ctx, getDatasetKey(progressId, relatedKind), func(w io.Writer) error { return writeRelated(w, c, schema) }, relatedRows, limits.StreamPoints)
+		if err != nil {
+			log.Print(err)
+			return "", nil, err
+		}
+		run.Datasets = append(run.Datasets, string(relatedKind.Type))
+		schemas[relatedKind.Type] = relatedSchema(schema, c.RelatedAttributes)
+	}
+	if len(c.Metadata) > 0 {
+		err = uploadData(ctx, getDatasetKey(progressId, metadataKind), func(w io.Writer) error { return writeMetadata(w, c, schema) }, len(c.Metadata), limits.StreamPoints)
+		if err != nil {
+			log.Print(err)
+			return "", nil, err
+		}
+		run.Datasets = append(run.Datasets, string(metadataKind.Type))
+		schemas[metadataKind.Type] = metadataSchema(schema, c.MetadataAttributes)
 	}
-	t := time.Now()
-	progressId := t.Format(layout2)[:14] + t.Format(layout2)[15:]
 
-	// Upload Data
-	err := uploadData(ctx, progressId, values)
+	// Save Run
+	err = saveRun(ctx, run)
 	if err != nil {
 		log.Print(err)
-		return "", err
+		return "", nil, err
 	}
 
 	// CreateDatasetGroup
-	datasetGroupArn, err := createDatasetGroup(ctx, progressId)
+	datasetGroupArn, err := createDatasetGroup(ctx, progressId, domain)
 	if err != nil {
 		log.Print(err)
-		return "", err
+		return "", nil, err
 	}
+	run.Arns[arnDatasetGroup] = datasetGroupArn
 
 	// CreateDataset
-	datasetArn, err := createDataset(ctx, progressId)
+	datasetArns := []string{}
+	for _, kind := range getDatasetKinds(run) {
+		datasetArn, err := createDataset(ctx, progressId, run.Frequency, kind, domain, schemas[kind.Type])
+		if err != nil {
+			log.Print(err)
+			return "", nil, err
+		}
+		run.Arns[kind.ArnKey] = datasetArn
+		datasetArns = append(datasetArns, datasetArn)
+	}
+
+	// UpdateDatasetGroup
+	err = updateDatasetGroup(ctx, datasetArns, datasetGroupArn)
 	if err != nil {
 		log.Print(err)
-		return "", err
+		return "", nil, err
 	}
 
-	// UpdateDatasetGroup
-	err = updateDatasetGroup(ctx, datasetArn, datasetGroupArn)
+	// Save Run
+	err = saveRun(ctx, run)
 	if err != nil {
 		log.Print(err)
-		return "", err
+		return "", nil, err
 	}
-	return progressId, nil
+	return progressId, problems, nil
 }
 
-func checkImport(ctx context.Context, id string)(string, error) {
+// checkImport imports every dataset of the run. It reports ACTIVE only when all imports are ACTIVE, so
+// the predictor is trained on the complete dataset group.
+func checkImport(ctx context.Context, run *Run)(string, error) {
+	status := "ACTIVE"
+	for _, kind := range getDatasetKinds(run) {
+		res, err := checkDatasetImport(ctx, run, kind)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(res, "FAILED") {
+			return res, nil
+		}
+		if res != "ACTIVE" {
+			status = res
+		}
+	}
+	return status, nil
+}
+
+func checkDatasetImport(ctx context.Context, run *Run, kind datasetKind)(string, error) {
+	id := run.ID
 	// GetDatasetImportJob
-	res := getDatasetImportJob(ctx, id)
-	log.Printf("%+v\n", res.Status)
-	if res.Status == nil {
+	res, found, err := getDatasetImportJob(ctx, run, kind)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
 		// CreateDatasetImportJob
-		ds := getDataset(ctx, id)
-		if ds.DatasetArn == nil {
-			return "", fmt.Errorf("Error: %s", "No Dataset.")
+		ds, found, err := getDataset(ctx, run, kind)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No Dataset.")
 		}
-		path := "s3://" + os.Getenv("BUCKET_NAME") + "/" + bucketPath + "/" + getForecastId(id) + ".csv"
-		_, err := createDatasetImportJob(ctx, id, aws.ToString(ds.DatasetArn), path, os.Getenv("FORECAST_ROLE_ARN"))
+		path := getBlobStore(ctx).URI(getDatasetKey(id, kind))
+		arn, err := createDatasetImportJob(ctx, id, kind, aws.ToString(ds.DatasetArn), path, os.Getenv("FORECAST_ROLE_ARN"))
 		if err != nil {
 			log.Print(err)
 			return "", err
 		}
+		run.Arns[kind.ImportArnKey] = arn
 		return "Start", nil
 	}
+	log.Printf("%+v\n", aws.ToString(res.Status))
+	run.Arns[kind.ImportArnKey] = aws.ToString(res.DatasetImportJobArn)
 	return aws.ToString(res.Status), nil
 }
 
-func checkPredictor(ctx context.Context, id string)(string, error) {
+func checkPredictor(ctx context.Context, run *Run)(string, error) {
+	id := run.ID
 	// GetPredictor
-	res := getPredictor(ctx, id)
-	if res.Status == nil {
+	res, found, err := getPredictor(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
 		// CreatePredictor
-		dsg := getDatasetGroup(ctx, id)
-		if dsg.DatasetGroupArn == nil {
-			return "", fmt.Errorf("Error: %s", "No DatasetGroup.")
+		dsg, found, err := getDatasetGroup(ctx, run)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No DatasetGroup.")
 		}
-		_, err := createPredictor(ctx, id, aws.ToString(dsg.DatasetGroupArn))
+		create := createPredictor
+		if isAutoPredictor(run) {
+			create = createAutoPredictor
+		}
+		arn, err := create(ctx, id, aws.ToString(dsg.DatasetGroupArn), run.Frequency, run.Horizon, run.Predictor)
 		if err != nil {
 			log.Print(err)
 			return "", err
 		}
+		run.Arns[arnPredictor] = arn
 		return "Start", nil
 	}
+	run.Arns[arnPredictor] = aws.ToString(res.PredictorArn)
 	return aws.ToString(res.Status), nil
 }
 
-func checkForecast(ctx context.Context, id string)(string, error) {
+func checkForecast(ctx context.Context, run *Run)(string, error) {
+	id := run.ID
 	// GetForecast
-	res := getForecast(ctx, id)
-	if res.Status == nil {
+	res, found, err := getForecast(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
 		// CreateForecast
-		pre := getPredictor(ctx, id)
-		if pre.PredictorArn == nil {
-			return "", fmt.Errorf("Error: %s", "No Predictor.")
+		pre, found, err := getPredictor(ctx, run)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No Predictor.")
 		}
-		_, err := createForecast(ctx, id, aws.ToString(pre.PredictorArn))
+		forecastTypes := []string{}
+		if run.Predictor != nil {
+			forecastTypes = run.Predictor.ForecastTypes
+		}
+		arn, err := createForecast(ctx, id, aws.ToString(pre.PredictorArn), forecastTypes)
 		if err != nil {
 			log.Print(err)
 			return "", err
 		}
+		run.Arns[arnForecast] = arn
 		return "Start", nil
 	}
+	run.Arns[arnForecast] = aws.ToString(res.ForecastArn)
 	return aws.ToString(res.Status), nil
 }
 
-func checkExport(ctx context.Context, id string)(string, error) {
+func checkExport(ctx context.Context, run *Run)(string, error) {
+	id := run.ID
 	// GetForecastExportJob
-	res := getForecastExportJob(ctx, id)
-	if res.Status == nil {
+	res, found, err := getForecastExportJob(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
 		// CreateForecastExportJob
-		fct := getForecast(ctx, id)
-		if fct.ForecastArn == nil {
-			return "", fmt.Errorf("Error: %s", "No Forecast.")
+		fct, found, err := getForecast(ctx, run)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No Forecast.")
 		}
-		path := "s3://" + os.Getenv("BUCKET_NAME") + "/" + bucketResultPath + "/" + getForecastId(id)
-		_, err := createForecastExportJob(ctx, id, aws.ToString(fct.ForecastArn), path, os.Getenv("FORECAST_ROLE_ARN"))
+		path := getBlobStore(ctx).URI(getResultPrefix(id))
+		arn, err := createForecastExportJob(ctx, id, aws.ToString(fct.ForecastArn), path, os.Getenv("FORECAST_ROLE_ARN"))
 		if err != nil {
 			log.Print(err)
 			return "", err
 		}
+		run.Arns[arnForecastExportJob] = arn
 		return "Start", nil
 	}
+	run.Arns[arnForecastExportJob] = aws.ToString(res.ForecastExportJobArn)
 	return aws.ToString(res.Status), nil
 }
 
-func getResult(ctx context.Context, id string)(string, error) {
-	resultData := ""
-	objectKey := getObjectKey(ctx, id)
-	if len(objectKey) == 0 {
-		return "", fmt.Errorf("Error: %s", "No ObjectKey.")
-	}
-	if s3Client == nil {
-		s3Client = getS3Client(ctx)
+func getResult(ctx context.Context, id string)(map[string][]ResultData, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("BUCKET_NAME")),
-		Key:    aws.String(objectKey),
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return nil, conflictError("Run is deleted.")
 	}
-	res, err := s3Client.GetObject(ctx, input)
+	res, err := readResult(ctx, run, getResultPrefix(id))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	if res == nil {
+		return nil, notFoundError("No ObjectKey.")
+	}
+	return res, nil
+}
 
-	rc := res.Body
-	defer rc.Close()
-	tmpData, err := ioutil.ReadAll(rc)
+// readResult returns the newest export under prefix whose merged part files hold one row per step
+// of the horizon for each item of the run. If none does, the newest export's problem is returned.
+// It returns nil when there is no part file yet.
+func readResult(ctx context.Context, run *Run, prefix string)(map[string][]ResultData, error) {
+	exports, err := getObjectKeys(ctx, prefix)
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+	if len(exports) == 0 {
+		return nil, nil
+	}
+	schema, err := constant.GetSchema()
 	if err != nil {
 		log.Println(err)
-		return "", err
+		return nil, err
 	}
-	var values []ResultData
-	if err := csvutil.Unmarshal(tmpData, &values); err != nil {
+	var first error
+	for _, keys := range exports {
+		res, err := readExport(ctx, run, keys, schema.ItemField())
+		if err == nil {
+			return res, nil
+		}
 		log.Println(err)
-		return "", err
-	} else {
-		for _, v := range values {
-			resultData += strconv.FormatFloat(v.P50, 'f', -1, 64) + ","
+		if first == nil {
+			first = err
 		}
 	}
-	return "[" + resultData[:len(resultData)-1] + "]", nil
+	return nil, first
 }
 
-func getS3Client(ctx context.Context) *s3.Client {
-	return s3.NewFromConfig(getConfig(ctx))
+// readExport streams the part files of one export in order and merges them.
+func readExport(ctx context.Context, run *Run, keys []string, itemField string)(map[string][]ResultData, error) {
+	store := getBlobStore(ctx)
+	resultData := []ResultData{}
+	for _, key := range keys {
+		rc, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := parseResult(rc, itemField)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		resultData = append(resultData, rows...)
+	}
+	res := groupResult(resultData)
+	if err := checkResult(run, res, len(resultData)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// checkResult rejects a merged export that does not hold exactly Horizon rows for every item of
+// the run, such as one read while Forecast is still writing its parts.
+func checkResult(run *Run, res map[string][]ResultData, rows int) error {
+	expected := len(run.Items) * run.Horizon
+	if rows != expected {
+		return conflictError("Result has " + strconv.Itoa(rows) + " rows, expected " + strconv.Itoa(expected) + ".")
+	}
+	for _, v := range run.Items {
+		if len(res[v]) != run.Horizon {
+			return conflictError("Result has " + strconv.Itoa(len(res[v])) + " rows for Item " + v + ", expected " + strconv.Itoa(run.Horizon) + ".")
+		}
+	}
+	return nil
+}
+
+// parseResult reads an export CSV. The item column is named after the schema's item field; every
+// column besides it and date is a quantile (p10, p50, p90, mean, ...).
+func parseResult(in io.Reader, itemField string)([]ResultData, error) {
+	r := csv.NewReader(in)
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range header {
+		if v == itemField {
+			header[i] = "item_id"
+		}
+	}
+	dec, err := csvutil.NewDecoder(r, header...)
+	if err != nil {
+		return nil, err
+	}
+	resultData := []ResultData{}
+	for {
+		var r resultRecord
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		record := dec.Record()
+		quantiles := make(map[string]float64)
+		for _, i := range dec.Unused() {
+			if len(record[i]) == 0 {
+				continue
+			}
+			v, err := strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, err
+			}
+			quantiles[header[i]] = v
+		}
+		resultData = append(resultData, ResultData{ItemID: r.ID, Date: r.Date, Quantiles: quantiles})
+	}
+	return resultData, nil
 }
 
-func getForecastClient(ctx context.Context) *forecast.Client {
-	return forecast.NewFromConfig(getConfig(ctx))
+func groupResult(resultData []ResultData) map[string][]ResultData {
+	res := make(map[string][]ResultData)
+	for _, v := range resultData {
+		res[v.ItemID] = append(res[v.ItemID], v)
+	}
+	for _, v := range res {
+		sort.SliceStable(v, func(i, j int) bool { return v[i].Date < v[j].Date })
+	}
+	return res
+}
+
+func getS3Client(ctx context.Context) *s3.Client {
+	return s3.NewFromConfig(getConfig(ctx))
 }
 
 func getConfig(ctx context.Context) aws.Config {
@@ -612,5 +975,5 @@ func getConfig(ctx context.Context) aws.Config {
 }
 
 func main() {
-	lambda.Start(HandleRequest)
+	lambda.Start(HandleEvent)
 }
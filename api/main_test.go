@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGetPartNumber(t *testing.T) {
+	tests := []struct {
+		key  string
+		stem string
+		n    int
+		ok   bool
+	}{
+		{"result/1/run_2024-01-01T00-00-00Z_part0.csv", "result/1/run_2024-01-01T00-00-00Z", 0, true},
+		{"result/1/run_2024-01-01T00-00-00Z_part12.csv", "result/1/run_2024-01-01T00-00-00Z", 12, true},
+		{"result/1/my_part_name_2024-01-01T00-00-00Z_part3.csv", "result/1/my_part_name_2024-01-01T00-00-00Z", 3, true},
+		{"result/1/run_2024-01-01T00-00-00Z_part0.json", "", 0, false},
+		{"result/1/run_2024-01-01T00-00-00Z_partx.csv", "", 0, false},
+		{"result/1/run_2024-01-01T00-00-00Z_part-1.csv", "", 0, false},
+		{"result/1/_SUCCESS", "", 0, false},
+		{"result/1/data.csv", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			stem, n, ok := getPartNumber(tt.key)
+			if stem != tt.stem || n != tt.n || ok != tt.ok {
+				t.Errorf("got (%s, %d, %v), want (%s, %d, %v)", stem, n, ok, tt.stem, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGetObjectKeys(t *testing.T) {
+	defer func(s BlobStore) { blobStore = s }(blobStore)
+	blobStore = &fileStore{root: t.TempDir()}
+	ctx := context.Background()
+	keys := []string{
+		"result/1/run_2024-01-01T00-00-00Z_part0.csv",
+		"result/1/run_2024-01-01T00-00-00Z_part1.csv",
+		"result/1/run_2024-01-02T00-00-00Z_part0.csv",
+		"result/1/run_2024-01-02T00-00-00Z_part2.csv",
+		"result/1/run_2024-01-02T00-00-00Z_part10.csv",
+		"result/1/run_2024-01-02T00-00-00Z_part1.csv",
+		"result/1/_SUCCESS",
+		"result/11/run_2024-01-03T00-00-00Z_part0.csv",
+	}
+	for _, v := range keys {
+		if err := blobStore.Put(ctx, v, strings.NewReader(""), "text/csv"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		want   [][]string
+	}{
+		{
+			prefix: "result/1/",
+			want: [][]string{
+				{
+					"result/1/run_2024-01-02T00-00-00Z_part0.csv",
+					"result/1/run_2024-01-02T00-00-00Z_part1.csv",
+					"result/1/run_2024-01-02T00-00-00Z_part2.csv",
+					"result/1/run_2024-01-02T00-00-00Z_part10.csv",
+				},
+				{
+					"result/1/run_2024-01-01T00-00-00Z_part0.csv",
+					"result/1/run_2024-01-01T00-00-00Z_part1.csv",
+				},
+			},
+		},
+		{
+			prefix: "result/2/",
+			want:   [][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			got, err := getObjectKeys(ctx, tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if strings.Join(got[i], ",") != strings.Join(tt.want[i], ",") {
+					t.Errorf("export %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckResult(t *testing.T) {
+	rows := func(itemId string, n int) []ResultData {
+		res := []ResultData{}
+		for i := 0; i < n; i++ {
+			res = append(res, ResultData{ItemID: itemId})
+		}
+		return res
+	}
+	run := &Run{Items: []string{"a", "b"}, Horizon: 2}
+	tests := []struct {
+		name    string
+		res     map[string][]ResultData
+		rows    int
+		wantErr bool
+	}{
+		{"complete", map[string][]ResultData{"a": rows("a", 2), "b": rows("b", 2)}, 4, false},
+		{"partial export", map[string][]ResultData{"a": rows("a", 2)}, 2, true},
+		{"missing item", map[string][]ResultData{"a": rows("a", 2), "c": rows("c", 2)}, 4, true},
+		{"uneven items", map[string][]ResultData{"a": rows("a", 3), "b": rows("b", 1)}, 4, true},
+		{"extra rows", map[string][]ResultData{"a": rows("a", 2), "b": rows("b", 2), "c": rows("c", 1)}, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResult(run, tt.res, tt.rows)
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			} else if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	data := "sku,date,p10,p50,p90\na,2024-01-01T00:00:00Z,1,2,3\nb,2024-01-01T00:00:00Z,,5,6\n"
+	res, err := parseResult(strings.NewReader(data), "sku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("got %d rows, want 2", len(res))
+	}
+	if res[0].ItemID != "a" || res[0].Date != "2024-01-01T00:00:00Z" || res[0].Quantiles["p50"] != 2 || len(res[0].Quantiles) != 3 {
+		t.Errorf("got %+v", res[0])
+	}
+	if _, ok := res[1].Quantiles["p10"]; ok || res[1].Quantiles["p90"] != 6 {
+		t.Errorf("got %+v", res[1])
+	}
+	if _, err := parseResult(strings.NewReader("sku,date,p50\na,2024-01-01,x\n"), "sku"); err == nil {
+		t.Error("expected an error for an invalid quantile")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+// Metrics are the backtest results of one algorithm of a run's predictor. AutoML predictors report
+// every algorithm they tried.
+type Metrics struct {
+	Algorithm string          `json:"algorithm"`
+	Windows   []MetricsWindow `json:"windows"`
+}
+
+// MetricsWindow is one backtest window, or the SUMMARY of all of them. Metrics Forecast could not
+// compute are left out.
+type MetricsWindow struct {
+	Type                        string             `json:"type"`
+	Start                       *time.Time         `json:"start,omitempty"`
+	End                         *time.Time         `json:"end,omitempty"`
+	ItemCount                   int                `json:"item_count"`
+	AverageWeightedQuantileLoss *float64           `json:"average_weighted_quantile_loss,omitempty"`
+	WeightedQuantileLosses      map[string]float64 `json:"weighted_quantile_losses,omitempty"`
+	ErrorMetrics                []ErrorMetric      `json:"error_metrics,omitempty"`
+}
+
+// ErrorMetric is the error of one forecast type, a quantile such as 0.5 or mean.
+type ErrorMetric struct {
+	ForecastType string   `json:"forecast_type"`
+	WAPE         *float64 `json:"wape,omitempty"`
+	RMSE         *float64 `json:"rmse,omitempty"`
+	MAPE         *float64 `json:"mape,omitempty"`
+	MASE         *float64 `json:"mase,omitempty"`
+}
+
+// getMetrics returns the accuracy of a run's predictor once it is ACTIVE.
+func getMetrics(ctx context.Context, id string)([]Metrics, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return nil, conflictError("Run is deleted.")
+	}
+	pre, found, err := getPredictor(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, notFoundError("No Predictor.")
+	}
+	if aws.ToString(pre.Status) != "ACTIVE" {
+		return nil, conflictError("Predictor is not active.")
+	}
+	input := &forecast.GetAccuracyMetricsInput{
+		PredictorArn: pre.PredictorArn,
+	}
+	res, err := forecastClient.GetAccuracyMetrics(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	metrics := []Metrics{}
+	for _, v := range res.PredictorEvaluationResults {
+		m := Metrics{Algorithm: aws.ToString(v.AlgorithmArn), Windows: []MetricsWindow{}}
+		for _, w := range v.TestWindows {
+			m.Windows = append(m.Windows, toMetricsWindow(w))
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func toMetricsWindow(w ftypes.WindowSummary) MetricsWindow {
+	res := MetricsWindow{
+		Type:      string(w.EvaluationType),
+		Start:     w.TestWindowStart,
+		End:       w.TestWindowEnd,
+		ItemCount: int(aws.ToInt32(w.ItemCount)),
+	}
+	if w.Metrics == nil {
+		return res
+	}
+	res.AverageWeightedQuantileLoss = finiteOrNil(w.Metrics.AverageWeightedQuantileLoss)
+	for _, v := range w.Metrics.WeightedQuantileLosses {
+		if v.Quantile == nil || finiteOrNil(v.LossValue) == nil {
+			continue
+		}
+		if res.WeightedQuantileLosses == nil {
+			res.WeightedQuantileLosses = make(map[string]float64)
+		}
+		res.WeightedQuantileLosses[strconv.FormatFloat(aws.ToFloat64(v.Quantile), 'f', -1, 64)] = aws.ToFloat64(v.LossValue)
+	}
+	for _, v := range w.Metrics.ErrorMetrics {
+		res.ErrorMetrics = append(res.ErrorMetrics, ErrorMetric{
+			ForecastType: aws.ToString(v.ForecastType),
+			WAPE:         finiteOrNil(v.WAPE),
+			RMSE:         finiteOrNil(v.RMSE),
+			MAPE:         finiteOrNil(v.MAPE),
+			MASE:         finiteOrNil(v.MASE),
+		})
+	}
+	return res
+}
+
+// finiteOrNil drops NaN and Inf, which JSON cannot carry.
+func finiteOrNil(v *float64) *float64 {
+	if v == nil || !isFinite(*v) {
+		return nil
+	}
+	return v
+}
+
+// Local backtest
+
+// localBacktest holds out the last windows of every item, refits the local model on the rest and
+// scores the held out points. Window i starts offset + i * horizon points before the end.
+func localBacktest(ds *localDataset, p *localPredictor, windows int, offset int)([]ftypes.WindowSummary, error) {
+	frequency := p.frequency
+	if len(frequency) == 0 {
+		frequency = ds.frequency
+	}
+	season := seasonLengths[frequency]
+	res := []ftypes.WindowSummary{}
+	scores := []*backtestScore{}
+	for i := 0; i < windows; i++ {
+		score := newBacktestScore(p.forecastTypes)
+		var start, end time.Time
+		for _, s := range ds.series {
+			cut := len(s.values) - offset - i * p.horizon
+			if cut < 2 {
+				continue
+			}
+			n := p.horizon
+			if cut + n > len(s.values) {
+				n = len(s.values) - cut
+			}
+			m := fitHoltWinters(s.values[:cut], season)
+			score.addItem(m, s.values[:cut], s.values[cut:cut + n], season)
+			if start.IsZero() || s.timestamps[cut].Before(start) {
+				start = s.timestamps[cut]
+			}
+			if s.timestamps[cut + n - 1].After(end) {
+				end = s.timestamps[cut + n - 1]
+			}
+		}
+		if score.items == 0 {
+			break
+		}
+		w := score.summary(ftypes.EvaluationTypeComputed)
+		w.TestWindowStart = aws.Time(start)
+		w.TestWindowEnd = aws.Time(end)
+		res = append(res, w)
+		scores = append(scores, score)
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("Error: %s", "Data is too short for the backtest windows.")
+	}
+	total := newBacktestScore(p.forecastTypes)
+	for _, v := range scores {
+		total.merge(v)
+	}
+	return append([]ftypes.WindowSummary{total.summary(ftypes.EvaluationTypeSummary)}, res...), nil
+}
+
+// backtestScore accumulates the sums behind each metric over the items of a window.
+type backtestScore struct {
+	forecastTypes []string
+	items         int
+	points        int
+	absActual     float64
+	absError      map[string]float64
+	sqError       map[string]float64
+	pctError      map[string]float64
+	pctPoints     int
+	scaledError   map[string]float64
+	scaledItems   int
+	quantileLoss  map[string]float64
+}
+
+func newBacktestScore(forecastTypes []string) *backtestScore {
+	return &backtestScore{
+		forecastTypes: forecastTypes,
+		absError:      make(map[string]float64),
+		sqError:       make(map[string]float64),
+		pctError:      make(map[string]float64),
+		scaledError:   make(map[string]float64),
+		quantileLoss:  make(map[string]float64),
+	}
+}
+
+func forecastTypeQuantile(forecastType string) float64 {
+	if q, err := strconv.ParseFloat(forecastType, 64); err == nil {
+		return q
+	}
+	return 0.5
+}
+
+// addItem scores one item. MASE scales by the in-sample seasonal naive error of the training data.
+func (s *backtestScore) addItem(m *holtWintersModel, train []float64, actual []float64, season int) {
+	lag := season
+	if lag < 1 || lag >= len(train) {
+		lag = 1
+	}
+	naive := 0.0
+	for t := lag; t < len(train); t++ {
+		naive += math.Abs(train[t] - train[t - lag])
+	}
+	naive /= float64(len(train) - lag)
+	s.items++
+	if naive > 0 {
+		s.scaledItems++
+	}
+	for h, y := range actual {
+		s.points++
+		s.absActual += math.Abs(y)
+		if y != 0 {
+			s.pctPoints++
+		}
+		for _, v := range s.forecastTypes {
+			q := forecastTypeQuantile(v)
+			f := m.quantile(h + 1, q)
+			e := y - f
+			s.absError[v] += math.Abs(e)
+			s.sqError[v] += e * e
+			if y != 0 {
+				s.pctError[v] += math.Abs(e / y)
+			}
+			if naive > 0 {
+				s.scaledError[v] += math.Abs(e) / naive / float64(len(actual))
+			}
+			s.quantileLoss[v] += q * math.Max(e, 0) + (1 - q) * math.Max(-e, 0)
+		}
+	}
+}
+
+func (s *backtestScore) merge(o *backtestScore) {
+	s.items += o.items
+	s.points += o.points
+	s.absActual += o.absActual
+	s.pctPoints += o.pctPoints
+	s.scaledItems += o.scaledItems
+	for _, v := range s.forecastTypes {
+		s.absError[v] += o.absError[v]
+		s.sqError[v] += o.sqError[v]
+		s.pctError[v] += o.pctError[v]
+		s.scaledError[v] += o.scaledError[v]
+		s.quantileLoss[v] += o.quantileLoss[v]
+	}
+}
+
+func (s *backtestScore) summary(evaluationType ftypes.EvaluationType) ftypes.WindowSummary {
+	metrics := &ftypes.Metrics{}
+	ratio := func(a float64, b float64) *float64 {
+		if b == 0 {
+			return nil
+		}
+		return aws.Float64(a / b)
+	}
+	losses := 0.0
+	quantiles := 0
+	for _, v := range s.forecastTypes {
+		metrics.ErrorMetrics = append(metrics.ErrorMetrics, ftypes.ErrorMetric{
+			ForecastType: aws.String(v),
+			WAPE:         ratio(s.absError[v], s.absActual),
+			RMSE:         aws.Float64(math.Sqrt(s.sqError[v] / float64(s.points))),
+			MAPE:         ratio(s.pctError[v], float64(s.pctPoints)),
+			MASE:         ratio(s.scaledError[v], float64(s.scaledItems)),
+		})
+		if v == "mean" {
+			continue
+		}
+		if loss := ratio(2 * s.quantileLoss[v], s.absActual); loss != nil {
+			metrics.WeightedQuantileLosses = append(metrics.WeightedQuantileLosses, ftypes.WeightedQuantileLoss{
+				Quantile:  aws.Float64(forecastTypeQuantile(v)),
+				LossValue: loss,
+			})
+			losses += *loss
+			quantiles++
+		}
+	}
+	if quantiles > 0 {
+		metrics.AverageWeightedQuantileLoss = aws.Float64(losses / float64(quantiles))
+	}
+	return ftypes.WindowSummary{
+		EvaluationType: evaluationType,
+		ItemCount:      aws.Int32(int32(s.items)),
+		Metrics:        metrics,
+	}
+}
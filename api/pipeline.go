@@ -0,0 +1,154 @@
+package main
+
+import (
+	"log"
+	"errors"
+	"time"
+	"strings"
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+const stageImport       string = "checkimport"
+const stagePredictor    string = "checkpredictor"
+const stageForecast     string = "checkforecast"
+const stageExport       string = "checkexport"
+const stageResult       string = "getresult"
+const stageExplain      string = "checkexplainability"
+const runStatusRunning  string = "RUNNING"
+const runStatusDone     string = "DONE"
+const runStatusFailed   string = "FAILED"
+
+// stageOrder lists the stages a run goes through. Each one is advanced by its check function until
+// the resource reports ACTIVE.
+var stageOrder = []string{stageImport, stagePredictor, stageForecast, stageExport, stageResult}
+
+// stageExplain is not in stageOrder; it only runs when requested with checkexplainability.
+var stageChecks = map[string]func(context.Context, *Run)(string, error){
+	stageImport:    checkImport,
+	stagePredictor: checkPredictor,
+	stageForecast:  checkForecast,
+	stageExport:    checkExport,
+	stageExplain:   checkExplainability,
+}
+
+func nextStage(stage string) string {
+	for i, v := range stageOrder {
+		if v == stage && i + 1 < len(stageOrder) {
+			return stageOrder[i + 1]
+		}
+	}
+	return stageResult
+}
+
+// advanceRun moves a run through as many stages as are already finished and saves the new state.
+func advanceRun(ctx context.Context, id string)(*Run, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == runStatusDeleting {
+		return run, advanceDeletion(ctx, run)
+	}
+	if run.Status != runStatusRunning {
+		return run, nil
+	}
+	for run.Status == runStatusRunning {
+		check, ok := stageChecks[run.Stage]
+		if !ok {
+			run.Status = runStatusDone
+			break
+		}
+		res, err := check(ctx, run)
+		var exists *ftypes.ResourceAlreadyExistsException
+		if errors.As(err, &exists) {
+			// Another invocation created the resource first.
+			break
+		} else if err != nil {
+			return run, err
+		}
+		if res == "ACTIVE" {
+			run.StageTimes[run.Stage] = time.Now()
+			run.Stage = nextStage(run.Stage)
+			if run.Stage == stageResult {
+				run.Status = runStatusDone
+			}
+		} else if strings.HasSuffix(res, "FAILED") {
+			run.Status = runStatusFailed
+		} else {
+			break
+		}
+	}
+	if err := saveActiveRun(ctx, run); err != nil {
+		return run, err
+	}
+	return run, nil
+}
+
+// checkStage runs a single stage check for the check* actions and records what it found.
+func checkStage(ctx context.Context, id string, stage string)(string, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return "", conflictError("Run is deleted.")
+	}
+	res, err := stageChecks[stage](ctx, run)
+	if err != nil {
+		return "", err
+	}
+	if err := saveActiveRun(ctx, run); err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// HandleScheduledEvent advances every unfinished run, so a run completes without a browser polling it.
+// Only the runs marked under runs/active/ are read; a marker left on a finished run is removed.
+func HandleScheduledEvent(ctx context.Context, event events.CloudWatchEvent) error {
+	ids, err := listActiveRunIds(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		run, err := advanceRun(withLookupCache(ctx), id)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if run.Status != runStatusRunning && run.Status != runStatusDeleting {
+			if err := getBlobStore(ctx).Delete(ctx, getActiveRunKey(id)); err != nil {
+				log.Print(err)
+			}
+		}
+		log.Printf("%s %s %s\n", run.ID, run.Stage, run.Status)
+	}
+	return nil
+}
+
+// HandleEvent dispatches API Gateway requests and EventBridge schedule events to their handlers.
+func HandleEvent(ctx context.Context, payload json.RawMessage)(interface{}, error) {
+	var probe struct {
+		DetailType string `json:"detail-type"`
+	}
+	json.Unmarshal(payload, &probe)
+	if len(probe.DetailType) > 0 {
+		var event events.CloudWatchEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		if event.DetailType == retentionDetailType {
+			return nil, HandleRetentionEvent(ctx, event)
+		}
+		return nil, HandleScheduledEvent(ctx, event)
+	}
+	var request events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	return HandleRequest(ctx, request)
+}
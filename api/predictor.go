@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+// PredictorOptions are the training settings of a run. An empty Algorithm lets AutoML choose, and
+// zero backtest settings leave Forecast's defaults. Explain trains an AutoPredictor, the only kind of
+// predictor Forecast can explain.
+type PredictorOptions struct {
+	Algorithm       string   `json:"algorithm,omitempty"`
+	ForecastTypes   []string `json:"forecast_types,omitempty"`
+	Holidays        string   `json:"holidays,omitempty"`
+	BacktestWindows int      `json:"backtest_windows,omitempty"`
+	BacktestOffset  int      `json:"backtest_offset,omitempty"`
+	Explain         bool     `json:"explain,omitempty"`
+}
+
+// getPredictorOptions reads the options from PREDICTOR_* environment variables, overridden by the
+// request's algorithm, forecast_types, holidays, backtest_windows, backtest_offset and explain parameters.
+func getPredictorOptions(d map[string]string)(*PredictorOptions, error) {
+	options := &PredictorOptions{}
+	get := func(param string, env string) string {
+		if v, ok := d[param]; ok {
+			return v
+		}
+		return os.Getenv(env)
+	}
+	if v := get("algorithm", "PREDICTOR_ALGORITHM"); len(v) > 0 && v != "auto" {
+		if _, ok := constant.AlgorithmArn(v); !ok {
+			return nil, validationError("Invalid Algorithm.")
+		}
+		options.Algorithm = v
+	}
+	if v := get("forecast_types", "PREDICTOR_FORECAST_TYPES"); len(v) > 0 {
+		types, ok := constant.ParseForecastTypes(v)
+		if !ok {
+			return nil, validationError("Invalid Forecast Types.")
+		}
+		options.ForecastTypes = types
+	}
+	if v := get("holidays", "PREDICTOR_HOLIDAYS"); len(v) > 0 {
+		if !constant.IsCountryCode(v) {
+			return nil, validationError("Invalid Holidays.")
+		}
+		options.Holidays = v
+	}
+	if v := get("backtest_windows", "PREDICTOR_BACKTEST_WINDOWS"); len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > constant.MaxBacktestWindows {
+			return nil, validationError("Invalid Backtest Windows.")
+		}
+		options.BacktestWindows = n
+	}
+	if v := get("backtest_offset", "PREDICTOR_BACKTEST_OFFSET"); len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, validationError("Invalid Backtest Offset.")
+		}
+		options.BacktestOffset = n
+	}
+	if v := get("explain", "PREDICTOR_EXPLAIN"); len(v) > 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, validationError("Invalid Explain.")
+		}
+		options.Explain = b
+	}
+	// AutoPredictor chooses its own algorithms and backtest windows.
+	if options.Explain && (len(options.Algorithm) > 0 || options.BacktestWindows > 0 || options.BacktestOffset > 0) {
+		return nil, validationError("Explain cannot be combined with algorithm or backtest settings.")
+	}
+	return options, nil
+}
+
+// checkBacktest applies Forecast's backtest rules once the horizon and the shortest item are known:
+// the offset is at least the horizon and at most half of the data.
+func (o *PredictorOptions) checkBacktest(horizon int, minPoints int) error {
+	if o.BacktestOffset == 0 {
+		return nil
+	}
+	if o.BacktestOffset < horizon {
+		return validationError("Backtest Offset must be at least Horizon.")
+	}
+	if o.BacktestOffset * 2 > minPoints {
+		return validationError("Backtest Offset must be at most half of the Data.")
+	}
+	return nil
+}
+
+// applyPredictorOptions fills the training settings of a CreatePredictor request. Runs recorded
+// before the options existed have none and use AutoML.
+func applyPredictorOptions(input *forecast.CreatePredictorInput, o *PredictorOptions) {
+	if o == nil {
+		o = &PredictorOptions{}
+	}
+	if arn, ok := constant.AlgorithmArn(o.Algorithm); ok {
+		input.AlgorithmArn = aws.String(arn)
+	} else {
+		input.PerformAutoML = aws.Bool(true)
+	}
+	if len(o.ForecastTypes) > 0 {
+		input.ForecastTypes = o.ForecastTypes
+	}
+	if len(o.Holidays) > 0 {
+		input.InputDataConfig.SupplementaryFeatures = []ftypes.SupplementaryFeature{
+			{
+				Name: aws.String("holiday"),
+				Value: aws.String(o.Holidays),
+			},
+		}
+	}
+	if o.BacktestWindows > 0 || o.BacktestOffset > 0 {
+		input.EvaluationParameters = &ftypes.EvaluationParameters{}
+		if o.BacktestWindows > 0 {
+			input.EvaluationParameters.NumberOfBacktestWindows = aws.Int32(int32(o.BacktestWindows))
+		}
+		if o.BacktestOffset > 0 {
+			input.EvaluationParameters.BackTestWindowOffset = aws.Int32(int32(o.BacktestOffset))
+		}
+	}
+}
+
+// applyAutoPredictorOptions fills a CreateAutoPredictor request. Holidays are an additional dataset
+// of an AutoPredictor rather than a supplementary feature.
+func applyAutoPredictorOptions(input *forecast.CreateAutoPredictorInput, o *PredictorOptions) {
+	if len(o.ForecastTypes) > 0 {
+		input.ForecastTypes = o.ForecastTypes
+	}
+	if len(o.Holidays) > 0 {
+		input.DataConfig.AdditionalDatasets = []ftypes.AdditionalDataset{
+			{
+				Name: aws.String("holiday"),
+				Configuration: map[string][]string{"CountryCode": {o.Holidays}},
+			},
+		}
+	}
+}
+
+// isAutoPredictor reports whether a run's predictor was created with CreateAutoPredictor.
+func isAutoPredictor(run *Run) bool {
+	return run.Predictor != nil && run.Predictor.Explain
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"errors"
+	"context"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecastquery"
+	qtypes "github.com/aws/aws-sdk-go-v2/service/forecastquery/types"
+)
+
+// The Forecast Query service answers one item per call, so runs with more items are read from the
+// export with getresult.
+const maxQueryItems int = 10
+
+// queryForecast reads a run's forecast with the Forecast Query service as soon as the forecast is
+// ACTIVE, without waiting for the export. Without itemId every item of the run is read.
+func queryForecast(ctx context.Context, id string, itemId string)(map[string][]ResultData, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return nil, conflictError("Run is deleted.")
+	}
+	fct, found, err := getForecast(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, notFoundError("No Forecast.")
+	}
+	if aws.ToString(fct.Status) != "ACTIVE" {
+		return nil, conflictError("Forecast is not active.")
+	}
+	itemIds := run.Items
+	if len(itemId) > 0 {
+		itemIds = []string{itemId}
+	} else if len(itemIds) > maxQueryItems {
+		return nil, validationError("Too many items to query; use getresult or give an item_id.")
+	}
+	schema, err := constant.GetSchema()
+	if err != nil {
+		return nil, err
+	}
+	if forecastQueryClient == nil {
+		forecastQueryClient = getForecastQueryClient(ctx)
+	}
+	resultData := []ResultData{}
+	for _, v := range itemIds {
+		rows, err := queryItem(ctx, aws.ToString(fct.ForecastArn), schema.ItemField(), v)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+		resultData = append(resultData, rows...)
+	}
+	return groupResult(resultData), nil
+}
+
+// queryItem turns the predictions of one item, a series of data points per forecast type, into one
+// row per date keyed like the export columns (p10, p50, mean, ...).
+func queryItem(ctx context.Context, forecastArn string, itemField string, itemId string)([]ResultData, error) {
+	input := &forecastquery.QueryForecastInput{
+		ForecastArn: aws.String(forecastArn),
+		Filters: map[string]string{itemField: itemId},
+	}
+	rows := make(map[string]*ResultData)
+	for {
+		res, err := forecastQueryClient.QueryForecast(ctx, input)
+		var missing *qtypes.ResourceNotFoundException
+		if errors.As(err, &missing) {
+			return nil, notFoundError("No Item " + itemId + ".")
+		} else if err != nil {
+			return nil, err
+		}
+		if res.Forecast != nil {
+			for name, points := range res.Forecast.Predictions {
+				for _, p := range points {
+					if p.Value == nil || !isFinite(*p.Value) {
+						continue
+					}
+					date := queryDate(aws.ToString(p.Timestamp))
+					if _, ok := rows[date]; !ok {
+						rows[date] = &ResultData{ItemID: itemId, Date: date, Quantiles: make(map[string]float64)}
+					}
+					rows[date].Quantiles[name] = *p.Value
+				}
+			}
+		}
+		if res.NextToken == nil {
+			break
+		}
+		input.NextToken = res.NextToken
+	}
+	resultData := make([]ResultData, 0, len(rows))
+	for _, v := range rows {
+		resultData = append(resultData, *v)
+	}
+	return resultData, nil
+}
+
+// queryDate rewrites a query timestamp in the layout of the export's date column, so both paths
+// return the same dates.
+func queryDate(s string) string {
+	t, err := parseTimestamp(s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(exportDateLayout)
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"io"
+	"fmt"
+	"sort"
+	"time"
+	"strconv"
+	"encoding/csv"
+	"encoding/json"
+
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+// datasetKind is one of the datasets of a run. The target dataset keeps the plain id<pid> name; the
+// others add a suffix to the dataset, import job and object names.
+type datasetKind struct {
+	Type         ftypes.DatasetType
+	Suffix       string
+	ArnKey       string
+	ImportArnKey string
+}
+
+var targetKind = datasetKind{ftypes.DatasetTypeTargetTimeSeries, "", arnDataset, arnDatasetImportJob}
+var relatedKind = datasetKind{ftypes.DatasetTypeRelatedTimeSeries, "_related", "dataset_related", "dataset_import_job_related"}
+var metadataKind = datasetKind{ftypes.DatasetTypeItemMetadata, "_metadata", "dataset_metadata", "dataset_import_job_metadata"}
+
+var datasetKinds = []datasetKind{targetKind, relatedKind, metadataKind}
+
+func (k datasetKind) name(id string) string {
+	return getForecastId(id) + k.Suffix
+}
+
+// getDatasetKinds returns the datasets of a run. Runs recorded before related datasets only have a target.
+func getDatasetKinds(run *Run) []datasetKind {
+	if len(run.Datasets) == 0 {
+		return []datasetKind{targetKind}
+	}
+	kinds := []datasetKind{}
+	for _, k := range datasetKinds {
+		for _, v := range run.Datasets {
+			if string(k.Type) == v {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	return kinds
+}
+
+// RelatedPoint is one row of a related time series: a timestamp and a value per covariate.
+type RelatedPoint struct {
+	Timestamp string
+	Values    map[string]float64
+}
+
+func (p *RelatedPoint) UnmarshalJSON(b []byte) error {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*p = RelatedPoint{Values: make(map[string]float64)}
+	for k, v := range raw {
+		if k == "timestamp" {
+			if err := json.Unmarshal(v, &p.Timestamp); err != nil {
+				return err
+			}
+			continue
+		}
+		var f float64
+		if err := json.Unmarshal(v, &f); err != nil {
+			return validationError("Invalid Related Value " + k + ".")
+		}
+		p.Values[k] = f
+	}
+	return nil
+}
+
+// Covariates are the optional related time series and item metadata of a senddata request.
+type Covariates struct {
+	Related            map[string][]RelatedPoint
+	RelatedAttributes  []string
+	Metadata           map[string]map[string]string
+	MetadataAttributes []string
+}
+
+// parseCovariates reads {"item_id": [{"timestamp", "<name>": value}]} related data and
+// {"item_id": {"<name>": value}} item metadata. Either may be empty. Covariates may not reuse the
+// attribute names of the target schema.
+func parseCovariates(related string, metadata string, schema *constant.Schema)(*Covariates, error) {
+	reserved := make(map[string]bool)
+	for _, v := range schema.Attributes {
+		reserved[v.Name] = true
+	}
+	c := &Covariates{}
+	if len(related) > 0 {
+		if err := json.Unmarshal([]byte(related), &c.Related); err != nil {
+			return nil, validationError("Invalid Related Data.")
+		}
+		attrs, err := getAttributeNames(len(c.Related), reserved, func(f func(map[string]bool)) {
+			for _, points := range c.Related {
+				for _, p := range points {
+					names := make(map[string]bool)
+					for k := range p.Values {
+						names[k] = true
+					}
+					f(names)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.RelatedAttributes = attrs
+	}
+	if len(metadata) > 0 {
+		raw := make(map[string]map[string]interface{})
+		if err := json.Unmarshal([]byte(metadata), &raw); err != nil {
+			return nil, validationError("Invalid Metadata.")
+		}
+		c.Metadata = make(map[string]map[string]string)
+		for itemId, values := range raw {
+			c.Metadata[itemId] = make(map[string]string)
+			for k, v := range values {
+				switch t := v.(type) {
+				case string :
+					c.Metadata[itemId][k] = t
+				case float64 :
+					c.Metadata[itemId][k] = strconv.FormatFloat(t, 'f', -1, 64)
+				case bool :
+					c.Metadata[itemId][k] = strconv.FormatBool(t)
+				default :
+					return nil, validationError("Invalid Metadata Value " + k + ".")
+				}
+			}
+		}
+		attrs, err := getAttributeNames(len(c.Metadata), reserved, func(f func(map[string]bool)) {
+			for _, values := range c.Metadata {
+				names := make(map[string]bool)
+				for k := range values {
+					names[k] = true
+				}
+				f(names)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.MetadataAttributes = attrs
+	}
+	return c, nil
+}
+
+// getAttributeNames checks that every row has the same, valid attribute names and returns them sorted.
+func getAttributeNames(n int, reserved map[string]bool, each func(func(map[string]bool)))([]string, error) {
+	var first map[string]bool
+	var err error
+	each(func(names map[string]bool) {
+		if err != nil {
+			return
+		}
+		if first == nil {
+			first = names
+			return
+		}
+		if len(names) != len(first) {
+			err = validationError("Attributes must be the same for every row.")
+			return
+		}
+		for k := range names {
+			if !first[k] {
+				err = validationError("Attributes must be the same for every row.")
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || len(first) == 0 {
+		return nil, validationError("No Attributes.")
+	}
+	attrs := []string{}
+	for k := range first {
+		if !constant.AttributeNamePattern.MatchString(k) || reserved[k] {
+			return nil, validationError("Invalid Attribute Name " + k + ".")
+		}
+		attrs = append(attrs, k)
+	}
+	sort.Strings(attrs)
+	return attrs, nil
+}
+
+// alignCovariates checks the covariates against the normalized target series. Related data must be
+// evenly spaced, without gaps, and cover every target timestamp plus the forecast horizon. Related
+// rows without timestamps are dated from the first target timestamp.
+func alignCovariates(c *Covariates, series map[string][]Point, frequency string, horizon int) error {
+	for itemId, points := range c.Related {
+		target, ok := series[itemId]
+		if !ok {
+			return validationError("Unknown Related Item " + itemId + ".")
+		}
+		if len(points) == 0 {
+			return validationError("No Related Data for " + itemId + ".")
+		}
+		start, err := time.Parse(layout4, target[0].Timestamp)
+		if err != nil {
+			return validationError("Invalid Timestamp " + target[0].Timestamp + ".")
+		}
+		monthEnd := isMonthEndSeries(target)
+		end, err := stepTime(start, frequency, len(target) - 1 + horizon, monthEnd)
+		if err != nil {
+			return err
+		}
+		tmp := make([]Point, len(points))
+		for i, p := range points {
+			tmp[i] = Point{Timestamp: p.Timestamp}
+		}
+		if len(tmp) > 0 && len(tmp[0].Timestamp) == 0 {
+			for i := range tmp {
+				t, err := stepTime(start, frequency, i, monthEnd)
+				if err != nil {
+					return err
+				}
+				tmp[i].Timestamp = t.Format(layout4)
+			}
+		}
+		tmp, err = normalizeTimestamps(tmp, frequency, start, len(points))
+		if err != nil && err != errTooManyPoints {
+			return err
+		}
+		if err == errTooManyPoints || len(tmp) != len(points) {
+			return validationError("Related Data of " + itemId + " has gaps.")
+		}
+		first, err := time.Parse(layout4, tmp[0].Timestamp)
+		if err != nil {
+			return validationError("Invalid Related Timestamp " + tmp[0].Timestamp + ".")
+		}
+		last, err := time.Parse(layout4, tmp[len(tmp) - 1].Timestamp)
+		if err != nil {
+			return validationError("Invalid Related Timestamp " + tmp[len(tmp) - 1].Timestamp + ".")
+		}
+		if first.After(start) || last.Before(end) {
+			return validationError(fmt.Sprintf("Related Data of %s must cover %s to %s.", itemId, start.Format(layout4), end.Format(layout4)))
+		}
+		for i := range points {
+			points[i].Timestamp = tmp[i].Timestamp
+		}
+	}
+	for itemId := range c.Metadata {
+		if _, ok := series[itemId]; !ok {
+			return validationError("Unknown Metadata Item " + itemId + ".")
+		}
+	}
+	return nil
+}
+
+func writeRelated(out io.Writer, c *Covariates, schema *constant.Schema) error {
+	w := csv.NewWriter(out)
+	w.Write(append([]string{schema.ItemField(), constant.TimestampField}, c.RelatedAttributes...))
+	itemIds := []string{}
+	for k := range c.Related {
+		itemIds = append(itemIds, k)
+	}
+	sort.Strings(itemIds)
+	for _, itemId := range itemIds {
+		for _, p := range c.Related[itemId] {
+			row := []string{itemId, p.Timestamp}
+			for _, a := range c.RelatedAttributes {
+				row = append(row, strconv.FormatFloat(p.Values[a], 'f', -1, 64))
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeMetadata(out io.Writer, c *Covariates, schema *constant.Schema) error {
+	w := csv.NewWriter(out)
+	w.Write(append([]string{schema.ItemField()}, c.MetadataAttributes...))
+	itemIds := []string{}
+	for k := range c.Metadata {
+		itemIds = append(itemIds, k)
+	}
+	sort.Strings(itemIds)
+	for _, itemId := range itemIds {
+		row := []string{itemId}
+		for _, a := range c.MetadataAttributes {
+			row = append(row, c.Metadata[itemId][a])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Schemas
+
+// targetSchema follows the attribute order of the schema file, which is also the CSV column order.
+func targetSchema(s *constant.Schema) *ftypes.Schema {
+	schema := &ftypes.Schema{}
+	for _, v := range s.Attributes {
+		schema.Attributes = append(schema.Attributes, ftypes.SchemaAttribute{
+			AttributeName: aws.String(v.Name),
+			AttributeType: ftypes.AttributeType(v.Type),
+		})
+	}
+	return schema
+}
+
+func relatedSchema(s *constant.Schema, attrs []string) *ftypes.Schema {
+	schema := &ftypes.Schema{
+		Attributes: []ftypes.SchemaAttribute{
+			{
+				AttributeName: aws.String(s.ItemField()),
+				AttributeType: ftypes.AttributeTypeString,
+			},
+			{
+				AttributeName: aws.String(constant.TimestampField),
+				AttributeType: ftypes.AttributeTypeTimestamp,
+			},
+		},
+	}
+	for _, v := range attrs {
+		schema.Attributes = append(schema.Attributes, ftypes.SchemaAttribute{
+			AttributeName: aws.String(v),
+			AttributeType: ftypes.AttributeTypeFloat,
+		})
+	}
+	return schema
+}
+
+func metadataSchema(s *constant.Schema, attrs []string) *ftypes.Schema {
+	schema := &ftypes.Schema{
+		Attributes: []ftypes.SchemaAttribute{
+			{
+				AttributeName: aws.String(s.ItemField()),
+				AttributeType: ftypes.AttributeTypeString,
+			},
+		},
+	}
+	for _, v := range attrs {
+		schema.Attributes = append(schema.Attributes, ftypes.SchemaAttribute{
+			AttributeName: aws.String(v),
+			AttributeType: ftypes.AttributeTypeString,
+		})
+	}
+	return schema
+}
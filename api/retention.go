@@ -0,0 +1,294 @@
+package main
+
+import (
+	"os"
+	"log"
+	"sort"
+	"time"
+	"errors"
+	"strings"
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+)
+
+const retentionDetailType  string = "Retention"
+const defaultRetentionTTL  string = "720h"
+const progressIdLength     int    = 17
+
+// RetentionDetail is the detail of the retention schedule event. DryRun only reports the runs that
+// would be deleted; RETENTION_DRY_RUN=true has the same effect.
+type RetentionDetail struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// RetentionReport lists the runs a retention pass found, by progress id.
+type RetentionReport struct {
+	DryRun  bool     `json:"dry_run"`
+	TTL     string   `json:"ttl"`
+	Expired []string `json:"expired"`
+	Kept    []string `json:"kept"`
+}
+
+// parseProgressId returns the progress id and creation time encoded in a resource name. Names that
+// are not idPrefix followed by a layout2 timestamp, and an optional dataset or scenario suffix, are
+// rejected.
+func parseProgressId(name string)(string, time.Time, bool) {
+	if !strings.HasPrefix(name, idPrefix) {
+		return "", time.Time{}, false
+	}
+	id := strings.TrimPrefix(name, idPrefix)
+	for _, k := range datasetKinds {
+		if len(k.Suffix) > 0 && strings.HasSuffix(id, k.Suffix) {
+			id = strings.TrimSuffix(id, k.Suffix)
+			break
+		}
+	}
+	// What-if forecasts are named id<pid>_<scenario name>.
+	if len(id) > progressIdLength && id[progressIdLength] == '_' && scenarioNamePattern.MatchString(id[progressIdLength + 1:]) {
+		id = id[:progressIdLength]
+	}
+	if len(id) != progressIdLength {
+		return "", time.Time{}, false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return "", time.Time{}, false
+		}
+	}
+	t, err := time.Parse(layout2, id[:14] + "." + id[14:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return id, t, true
+}
+
+// isProgressId reports whether id is exactly a progress id, so it can be used in object keys.
+func isProgressId(id string) bool {
+	pid, _, ok := parseProgressId(idPrefix + id)
+	return ok && pid == id
+}
+
+func getRetentionTTL()(time.Duration, error) {
+	ttl := os.Getenv("RETENTION_TTL")
+	if len(ttl) < 1 {
+		ttl = defaultRetentionTTL
+	}
+	return time.ParseDuration(ttl)
+}
+
+// listConventionIds collects the progress ids of every Forecast resource and stored object named
+// after a run.
+func listConventionIds(ctx context.Context)(map[string]time.Time, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+	names := []string{}
+
+	p1 := forecast.NewListDatasetGroupsPaginator(forecastClient, &forecast.ListDatasetGroupsInput{})
+	for p1.HasMorePages() {
+		res, err := p1.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.DatasetGroups {
+			names = append(names, aws.ToString(v.DatasetGroupName))
+		}
+	}
+	p2 := forecast.NewListDatasetsPaginator(forecastClient, &forecast.ListDatasetsInput{})
+	for p2.HasMorePages() {
+		res, err := p2.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Datasets {
+			names = append(names, aws.ToString(v.DatasetName))
+		}
+	}
+	p3 := forecast.NewListDatasetImportJobsPaginator(forecastClient, &forecast.ListDatasetImportJobsInput{})
+	for p3.HasMorePages() {
+		res, err := p3.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.DatasetImportJobs {
+			names = append(names, aws.ToString(v.DatasetImportJobName))
+		}
+	}
+	p4 := forecast.NewListPredictorsPaginator(forecastClient, &forecast.ListPredictorsInput{})
+	for p4.HasMorePages() {
+		res, err := p4.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Predictors {
+			names = append(names, aws.ToString(v.PredictorName))
+		}
+	}
+	p5 := forecast.NewListForecastsPaginator(forecastClient, &forecast.ListForecastsInput{})
+	for p5.HasMorePages() {
+		res, err := p5.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Forecasts {
+			names = append(names, aws.ToString(v.ForecastName))
+		}
+	}
+	p6 := forecast.NewListForecastExportJobsPaginator(forecastClient, &forecast.ListForecastExportJobsInput{})
+	for p6.HasMorePages() {
+		res, err := p6.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.ForecastExportJobs {
+			names = append(names, aws.ToString(v.ForecastExportJobName))
+		}
+	}
+	p7 := forecast.NewListExplainabilitiesPaginator(forecastClient, &forecast.ListExplainabilitiesInput{})
+	for p7.HasMorePages() {
+		res, err := p7.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Explainabilities {
+			names = append(names, aws.ToString(v.ExplainabilityName))
+		}
+	}
+	p8 := forecast.NewListExplainabilityExportsPaginator(forecastClient, &forecast.ListExplainabilityExportsInput{})
+	for p8.HasMorePages() {
+		res, err := p8.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.ExplainabilityExports {
+			names = append(names, aws.ToString(v.ExplainabilityExportName))
+		}
+	}
+	p9 := forecast.NewListWhatIfAnalysesPaginator(forecastClient, &forecast.ListWhatIfAnalysesInput{})
+	for p9.HasMorePages() {
+		res, err := p9.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.WhatIfAnalyses {
+			names = append(names, aws.ToString(v.WhatIfAnalysisName))
+		}
+	}
+	p10 := forecast.NewListWhatIfForecastsPaginator(forecastClient, &forecast.ListWhatIfForecastsInput{})
+	for p10.HasMorePages() {
+		res, err := p10.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.WhatIfForecasts {
+			names = append(names, aws.ToString(v.WhatIfForecastName))
+		}
+	}
+	p11 := forecast.NewListWhatIfForecastExportsPaginator(forecastClient, &forecast.ListWhatIfForecastExportsInput{})
+	for p11.HasMorePages() {
+		res, err := p11.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.WhatIfForecastExports {
+			names = append(names, aws.ToString(v.WhatIfForecastExportName))
+		}
+	}
+
+	// csv/id<pid>.csv, result/id<pid>/..., explainability/id<pid>/..., whatif/id<pid>/..., runs/id<pid>.json
+	for _, dir := range []string{bucketPath, bucketResultPath, bucketExplainPath, bucketWhatIfPath, bucketRunPath} {
+		keys, err := getBlobStore(ctx).List(ctx, dir + "/" + idPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			name := strings.SplitN(strings.TrimPrefix(key, dir + "/"), "/", 2)[0]
+			names = append(names, strings.SplitN(name, ".", 2)[0])
+		}
+	}
+
+	ids := make(map[string]time.Time)
+	for _, v := range names {
+		if id, t, ok := parseProgressId(v); ok {
+			ids[id] = t
+		}
+	}
+	return ids, nil
+}
+
+// expireRuns starts the deletion of every run older than the TTL. Deletion then continues on the
+// pipeline schedule like a deleterun request.
+func expireRuns(ctx context.Context, dryRun bool)(*RetentionReport, error) {
+	ttl, err := getRetentionTTL()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := listConventionIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &RetentionReport{DryRun: dryRun, TTL: ttl.String(), Expired: []string{}, Kept: []string{}}
+	now := time.Now()
+	for _, id := range sortedIds(ids) {
+		if now.Sub(ids[id]) <= ttl {
+			report.Kept = append(report.Kept, id)
+			continue
+		}
+		run, err := loadRun(ctx, id)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == codeNotFound {
+			// Resources created before the registry existed are deleted by name.
+			run = &Run{
+				ID:         id,
+				Arns:       make(map[string]string),
+				StageTimes: make(map[string]time.Time),
+				CreatedAt:  ids[id],
+			}
+		} else if err != nil {
+			log.Print(err)
+			continue
+		}
+		if run.Status == runStatusDeleted {
+			continue
+		}
+		report.Expired = append(report.Expired, id)
+		if dryRun {
+			continue
+		}
+		if err := startDeletion(withLookupCache(ctx), run); err != nil {
+			log.Print(err)
+		}
+	}
+	return report, nil
+}
+
+func sortedIds(ids map[string]time.Time) []string {
+	res := []string{}
+	for k := range ids {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// HandleRetentionEvent expires old runs on the retention schedule.
+func HandleRetentionEvent(ctx context.Context, event events.CloudWatchEvent) error {
+	var detail RetentionDetail
+	if len(event.Detail) > 0 {
+		if err := json.Unmarshal(event.Detail, &detail); err != nil {
+			return err
+		}
+	}
+	dryRun := detail.DryRun || os.Getenv("RETENTION_DRY_RUN") == "true"
+	report, err := expireRuns(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	jsonBytes, _ := json.Marshal(report)
+	log.Print(string(jsonBytes))
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+	"testing"
+)
+
+func TestParseProgressId(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	tests := []struct {
+		name string
+		id   string
+		ok   bool
+	}{
+		{"id20240102030405123", "20240102030405123", true},
+		{"id20240102030405123_related", "20240102030405123", true},
+		{"id20240102030405123_metadata", "20240102030405123", true},
+		{"id20240102030405123_high_demand", "20240102030405123", true},
+		{"id20240102030405123_1st", "", false},
+		{"id20240102030405123_", "", false},
+		{"id2024010203040512", "", false},
+		{"id202401020304051234", "", false},
+		{"id2024010203040512x", "", false},
+		{"id20241302030405123", "", false},
+		{"xx20240102030405123", "", false},
+		{"20240102030405123", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, got, ok := parseProgressId(tt.name)
+			if id != tt.id || ok != tt.ok {
+				t.Fatalf("got (%s, %v), want (%s, %v)", id, ok, tt.id, tt.ok)
+			}
+			if ok && !got.Equal(created) {
+				t.Errorf("got time %v, want %v", got, created)
+			}
+		})
+	}
+}
+
+func TestIsProgressId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"20240102030405123", true},
+		{"20240102030405123_related", false},
+		{"20240102030405123_high", false},
+		{"../20240102030405", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isProgressId(tt.id); got != tt.want {
+			t.Errorf("isProgressId(%q): got %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
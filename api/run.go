@@ -0,0 +1,211 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+	"bytes"
+	"strconv"
+	"strings"
+	"context"
+	"io/ioutil"
+	"encoding/json"
+)
+
+// Run is the registry entry of one senddata request, keyed by its progress id. It is stored as
+// runs/id<progressId>.json in the bucket.
+type Run struct {
+	ID         string               `json:"id"`
+	Items      []string             `json:"items"`
+	Frequency  string               `json:"frequency"`
+	Horizon    int                  `json:"horizon"`
+	Datasets   []string             `json:"datasets,omitempty"`
+	Predictor  *PredictorOptions    `json:"predictor,omitempty"`
+	Scenarios  []Scenario           `json:"scenarios,omitempty"`
+	Stage      string               `json:"stage"`
+	Status     string               `json:"status"`
+	Arns       map[string]string    `json:"arns"`
+	StageTimes map[string]time.Time `json:"stage_times"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+	// version is the stored manifest this run was loaded from, so saveRun only replaces that one.
+	version    string
+}
+
+const arnDatasetGroup        string = "dataset_group"
+const arnDataset             string = "dataset"
+const arnDatasetImportJob    string = "dataset_import_job"
+const arnPredictor           string = "predictor"
+const arnForecast            string = "forecast"
+const arnForecastExportJob   string = "forecast_export_job"
+const arnExplain             string = "explainability"
+const arnExplainExport       string = "explainability_export"
+const arnWhatIf              string = "what_if_analysis"
+const arnWhatIfForecast      string = "what_if_forecast"
+const arnWhatIfExport        string = "what_if_forecast_export"
+
+func newRun(frequency string, horizon string)(*Run, error) {
+	run := &Run{
+		Frequency:  defaultFrequency,
+		Horizon:    defaultHorizon,
+		Arns:       make(map[string]string),
+		StageTimes: make(map[string]time.Time),
+	}
+	if len(frequency) > 0 {
+		if _, err := addFrequency(time.Time{}, frequency, 1); err != nil {
+			return nil, err
+		}
+		run.Frequency = frequency
+	}
+	if len(horizon) > 0 {
+		h, err := strconv.Atoi(horizon)
+		if err != nil || h < 1 {
+			return nil, validationError("Invalid Horizon.")
+		}
+		run.Horizon = h
+	}
+	return run, nil
+}
+
+// saveRun writes the manifest only if it was not saved by another request since run was loaded,
+// and returns errBlobChanged otherwise. Runs that the scheduled event advances are also marked
+// under runs/active/.
+func saveRun(ctx context.Context, run *Run) error {
+	run.UpdatedAt = time.Now()
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	store := getBlobStore(ctx)
+	version, err := store.PutIfVersion(ctx, getRunKey(run.ID), b, "application/json", run.version)
+	if err != nil {
+		return err
+	}
+	run.version = version
+	if run.Status == runStatusRunning || run.Status == runStatusDeleting {
+		return store.Put(ctx, getActiveRunKey(run.ID), bytes.NewReader(nil), "text/plain")
+	}
+	return store.Delete(ctx, getActiveRunKey(run.ID))
+}
+
+// saveActiveRun saves a run that was advanced by a stage check. If another request saved it first,
+// its state is kept instead, unless deleterun marked it for deletion, which is a conflict; DELETING
+// is never overwritten with an active stage.
+func saveActiveRun(ctx context.Context, run *Run) error {
+	err := saveRun(ctx, run)
+	if err != errBlobChanged {
+		return err
+	}
+	current, err := loadRun(ctx, run.ID)
+	if err != nil {
+		return err
+	}
+	if current.Status == runStatusDeleting || current.Status == runStatusDeleted {
+		return conflictError("Run is deleted.")
+	}
+	*run = *current
+	return nil
+}
+
+// saveDeletingRun saves a run that deletion advanced. If a stage check saved it in the meantime,
+// the deletion state is written over that save, keeping any resource it recorded.
+func saveDeletingRun(ctx context.Context, run *Run) error {
+	for i := 0; i < 3; i++ {
+		err := saveRun(ctx, run)
+		if err != errBlobChanged {
+			return err
+		}
+		current, err := loadRun(ctx, run.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status == runStatusDeleted {
+			*run = *current
+			return nil
+		}
+		for k, v := range current.Arns {
+			if _, ok := run.Arns[k]; !ok {
+				run.Arns[k] = v
+			}
+		}
+		run.version = current.version
+	}
+	return conflictError("Run was changed by another request.")
+}
+
+func loadRun(ctx context.Context, id string)(*Run, error) {
+	if !isProgressId(id) {
+		return nil, notFoundError("No Run.")
+	}
+	rc, version, err := getBlobStore(ctx).GetVersion(ctx, getRunKey(id))
+	if err == errBlobNotFound {
+		return nil, notFoundError("No Run.")
+	} else if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	run := &Run{}
+	if err := json.Unmarshal(b, run); err != nil {
+		return nil, err
+	}
+	if run.Arns == nil {
+		run.Arns = make(map[string]string)
+	}
+	if run.StageTimes == nil {
+		run.StageTimes = make(map[string]time.Time)
+	}
+	run.version = version
+	return run, nil
+}
+
+// listRuns returns every registered run, newest first. Manifests that cannot be read are logged and
+// left out.
+func listRuns(ctx context.Context)([]*Run, error) {
+	ids, err := listRunIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runs := []*Run{}
+	for _, id := range ids {
+		run, err := loadRun(ctx, id)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].CreatedAt.After(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+// listActiveRunIds returns the runs marked under runs/active/, those still RUNNING or DELETING.
+func listActiveRunIds(ctx context.Context)([]string, error) {
+	prefix := bucketRunPath + "/" + bucketActivePath + "/" + idPrefix
+	keys, err := getBlobStore(ctx).List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, prefix))
+	}
+	return ids, nil
+}
+
+func listRunIds(ctx context.Context)([]string, error) {
+	keys, err := getBlobStore(ctx).List(ctx, bucketRunPath + "/" + idPrefix)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, key := range keys {
+		if strings.HasSuffix(key, ".json") {
+			ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(key, bucketRunPath + "/" + idPrefix), ".json"))
+		}
+	}
+	return ids, nil
+}
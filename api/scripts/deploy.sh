@@ -3,7 +3,7 @@ echo 'Updating API Lambda-Function...'
 cd `dirname $0`/../
 rm function.zip
 rm bootstrap
-GOARCH=arm64 GOOS=linux CGO_ENABLED=0 go build -o bootstrap main.go
+GOARCH=arm64 GOOS=linux CGO_ENABLED=0 go build -o bootstrap .
 zip -g function.zip bootstrap
 aws lambda update-function-code \
 	--profile default \
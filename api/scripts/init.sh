@@ -9,7 +9,7 @@ echo 'Create Lambda-Function...'
 cd `dirname $0`/../
 rm function.zip
 rm bootstrap
-GOARCH=arm64 GOOS=linux CGO_ENABLED=0 go build -o bootstrap main.go
+GOARCH=arm64 GOOS=linux CGO_ENABLED=0 go build -o bootstrap .
 zip -g function.zip bootstrap
 aws lambda create-function \
 	--function-name your_api_function_name \
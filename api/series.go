@@ -0,0 +1,214 @@
+package main
+
+import (
+	"sort"
+	"time"
+	"strconv"
+	"strings"
+	"encoding/json"
+)
+
+type Point struct {
+	Timestamp string  `json:"timestamp"`
+	Value     float64 `json:"value"`
+	// Missing is set for null values and for steps absent from a timestamped series.
+	Missing   bool    `json:"-"`
+}
+
+var timestampLayouts = []string{
+	layout4,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// UnmarshalJSON accepts a bare value as well as a {"timestamp", "value"} object. A value is a number,
+// null for a missing value, or a string such as "NaN" or "Inf".
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var tmp struct {
+		Timestamp string          `json:"timestamp"`
+		Value     json.RawMessage `json:"value"`
+	}
+	raw := json.RawMessage(b)
+	if len(b) > 0 && b[0] == '{' {
+		if err := json.Unmarshal(b, &tmp); err != nil {
+			return err
+		}
+		raw = tmp.Value
+	}
+	*p = Point{Timestamp: tmp.Timestamp}
+	if len(raw) == 0 || string(raw) == "null" {
+		p.Missing = true
+		return nil
+	}
+	if err := json.Unmarshal(raw, &p.Value); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return err
+	}
+	p.Value = v
+	return nil
+}
+
+// parseSeries accepts either {"item_id": [points]} or a plain [points] array for a single item.
+func parseSeries(data string)(map[string][]Point, error) {
+	series := make(map[string][]Point)
+	if err := json.Unmarshal([]byte(data), &series); err == nil {
+		return series, nil
+	}
+	var points []Point
+	if err := json.Unmarshal([]byte(data), &points); err != nil {
+		return nil, err
+	}
+	series[defaultItemId] = points
+	return series, nil
+}
+
+func sortedItemIds(series map[string][]Point) []string {
+	itemIds := make([]string, 0, len(series))
+	for k := range series {
+		itemIds = append(itemIds, k)
+	}
+	sort.Strings(itemIds)
+	return itemIds
+}
+
+func parseTimestamp(s string)(time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, l := range timestampLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, validationError("Invalid Timestamp " + s + ".")
+}
+
+// addFrequency moves t by n steps of a Forecast data frequency.
+func addFrequency(t time.Time, frequency string, n int)(time.Time, error) {
+	switch frequency {
+	case "Y" :
+		return t.AddDate(n, 0, 0), nil
+	case "M" :
+		return t.AddDate(0, n, 0), nil
+	case "W" :
+		return t.AddDate(0, 0, 7 * n), nil
+	case "D" :
+		return t.AddDate(0, 0, n), nil
+	case "H" :
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "30min", "15min", "10min", "5min", "1min" :
+		m, _ := time.ParseDuration(strings.TrimSuffix(frequency, "in"))
+		return t.Add(time.Duration(n) * m), nil
+	}
+	return time.Time{}, validationError("Invalid Frequency.")
+}
+
+// isStep reports whether t is exactly n steps after base, the time stepTime gives.
+func isStep(base time.Time, t time.Time, frequency string, n int, monthEnd bool) bool {
+	expected, err := stepTime(base, frequency, n, monthEnd)
+	return err == nil && t.Equal(expected)
+}
+
+// isMonthEndSeries reports whether the first two points are both on the last day of their month.
+// Monthly and yearly steps of such a series stay on month ends.
+func isMonthEndSeries(points []Point) bool {
+	if len(points) < 2 {
+		return false
+	}
+	for _, p := range points[:2] {
+		t, err := parseTimestamp(p.Timestamp)
+		if err != nil || t.AddDate(0, 0, 1).Day() != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// stepTime is addFrequency with months and years kept on the calendar, so that n months after
+// January 31 is the last day of the month rather than a date in the month after. With monthEnd
+// every step is the last day of its month, so February 28 is followed by March 31.
+func stepTime(base time.Time, frequency string, n int, monthEnd bool)(time.Time, error) {
+	months := n
+	switch frequency {
+	case "Y" :
+		months = 12 * n
+	case "M" :
+	default :
+		return addFrequency(base, frequency, n)
+	}
+	first := time.Date(base.Year(), base.Month() + time.Month(months), 1, base.Hour(), base.Minute(), base.Second(), base.Nanosecond(), base.Location())
+	day := base.Day()
+	if monthEnd {
+		day = 31
+	}
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day - 1), nil
+}
+
+var errTooManyPoints = validationError("Invalid Data Size.")
+
+// normalizeTimestamps checks that caller-supplied timestamps are ordered and aligned to the frequency,
+// and rewrites them in the layout Forecast imports. Skipped steps are inserted as missing points for
+// cleansing to fill or reject, and the series is rejected as soon as the gaps make it longer than
+// maxPoints. Points without any timestamps are back-dated from now.
+func normalizeTimestamps(points []Point, frequency string, now time.Time, maxPoints int)([]Point, error) {
+	given := 0
+	for _, p := range points {
+		if len(p.Timestamp) > 0 {
+			given++
+		}
+	}
+	if given == 0 {
+		base := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		for i := range points {
+			t, err := addFrequency(base, frequency, i - len(points))
+			if err != nil {
+				return nil, err
+			}
+			points[i].Timestamp = t.Format(layout4)
+		}
+		return points, nil
+	}
+	if given != len(points) {
+		return nil, validationError("Missing Timestamp.")
+	}
+	var base time.Time
+	monthEnd := isMonthEndSeries(points)
+	res := make([]Point, 0, len(points))
+	for i := range points {
+		t, err := parseTimestamp(points[i].Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			base = t
+		} else {
+			for n := len(res); !isStep(base, t, frequency, n, monthEnd); n++ {
+				expected, err := stepTime(base, frequency, n, monthEnd)
+				if err != nil {
+					return nil, err
+				}
+				if !expected.Before(t) {
+					return nil, validationError("Invalid Timestamp Interval at " + points[i].Timestamp + ".")
+				}
+				res = append(res, Point{Timestamp: expected.Format(layout4), Missing: true})
+				// The point at t still follows.
+				if len(res) >= maxPoints {
+					return nil, errTooManyPoints
+				}
+			}
+		}
+		points[i].Timestamp = t.Format(layout4)
+		res = append(res, points[i])
+	}
+	return res, nil
+}
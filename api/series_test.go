@@ -0,0 +1,202 @@
+package main
+
+import (
+	"time"
+	"testing"
+)
+
+func TestStepTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		frequency string
+		n         int
+		monthEnd  bool
+		want      string
+	}{
+		{"day", "2023-01-30 00:00:00", "D", 3, false, "2023-02-02 00:00:00"},
+		{"hour", "2023-01-31 23:00:00", "H", 2, false, "2023-02-01 01:00:00"},
+		{"15 minutes", "2023-01-01 00:00:00", "15min", 3, false, "2023-01-01 00:45:00"},
+		{"month", "2023-01-15 00:00:00", "M", 1, false, "2023-02-15 00:00:00"},
+		{"month clamped", "2023-01-31 00:00:00", "M", 1, false, "2023-02-28 00:00:00"},
+		{"month clamped leap", "2024-01-31 00:00:00", "M", 1, false, "2024-02-29 00:00:00"},
+		{"month from 28th", "2023-02-28 00:00:00", "M", 1, false, "2023-03-28 00:00:00"},
+		{"month end", "2023-02-28 00:00:00", "M", 1, true, "2023-03-31 00:00:00"},
+		{"month end two steps", "2023-02-28 00:00:00", "M", 2, true, "2023-04-30 00:00:00"},
+		{"year clamped", "2024-02-29 00:00:00", "Y", 1, false, "2025-02-28 00:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := time.Parse(layout4, tt.base)
+			got, err := stepTime(base, tt.frequency, tt.n, tt.monthEnd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format(layout4) != tt.want {
+				t.Errorf("got %s, want %s", got.Format(layout4), tt.want)
+			}
+		})
+	}
+	if _, err := stepTime(time.Now(), "X", 1, false); err == nil {
+		t.Error("expected an error for an invalid frequency")
+	}
+}
+
+func TestIsMonthEndSeries(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps []string
+		want       bool
+	}{
+		{"month ends", []string{"2023-02-28", "2023-03-31"}, true},
+		{"leap february", []string{"2024-01-31", "2024-02-29"}, true},
+		{"28th", []string{"2023-02-28", "2023-03-28"}, false},
+		{"first only", []string{"2023-01-31", "2023-02-27"}, false},
+		{"single point", []string{"2023-01-31"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := []Point{}
+			for _, v := range tt.timestamps {
+				points = append(points, Point{Timestamp: v})
+			}
+			if got := isMonthEndSeries(points); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTimestamps(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps []string
+		frequency  string
+		maxPoints  int
+		want       []string
+		missing    []int
+		wantErr    bool
+	}{
+		{
+			name:       "daily",
+			timestamps: []string{"2023-01-01", "2023-01-02", "2023-01-03"},
+			frequency:  "D",
+			maxPoints:  10,
+			want:       []string{"2023-01-01 00:00:00", "2023-01-02 00:00:00", "2023-01-03 00:00:00"},
+		},
+		{
+			name:       "daily gap",
+			timestamps: []string{"2023-01-01", "2023-01-04"},
+			frequency:  "D",
+			maxPoints:  10,
+			want:       []string{"2023-01-01 00:00:00", "2023-01-02 00:00:00", "2023-01-03 00:00:00", "2023-01-04 00:00:00"},
+			missing:    []int{1, 2},
+		},
+		{
+			name:       "monthly on the 28th",
+			timestamps: []string{"2023-02-28", "2023-03-28", "2023-04-28"},
+			frequency:  "M",
+			maxPoints:  10,
+			want:       []string{"2023-02-28 00:00:00", "2023-03-28 00:00:00", "2023-04-28 00:00:00"},
+		},
+		{
+			name:       "monthly on month ends",
+			timestamps: []string{"2023-01-31", "2023-02-28", "2023-03-31", "2023-04-30"},
+			frequency:  "M",
+			maxPoints:  10,
+			want:       []string{"2023-01-31 00:00:00", "2023-02-28 00:00:00", "2023-03-31 00:00:00", "2023-04-30 00:00:00"},
+		},
+		{
+			name:       "month end gap",
+			timestamps: []string{"2023-02-28", "2023-03-31", "2023-05-31"},
+			frequency:  "M",
+			maxPoints:  10,
+			want:       []string{"2023-02-28 00:00:00", "2023-03-31 00:00:00", "2023-04-30 00:00:00", "2023-05-31 00:00:00"},
+			missing:    []int{2},
+		},
+		{
+			name:       "uneven month ends",
+			timestamps: []string{"2023-02-28", "2023-03-28", "2023-04-30"},
+			frequency:  "M",
+			maxPoints:  10,
+			wantErr:    true,
+		},
+		{
+			name:       "misaligned",
+			timestamps: []string{"2023-01-01 00:00:00", "2023-01-01 01:30:00"},
+			frequency:  "H",
+			maxPoints:  10,
+			wantErr:    true,
+		},
+		{
+			name:       "unordered",
+			timestamps: []string{"2023-01-02", "2023-01-01"},
+			frequency:  "D",
+			maxPoints:  10,
+			wantErr:    true,
+		},
+		{
+			name:       "partly timestamped",
+			timestamps: []string{"2023-01-01", ""},
+			frequency:  "D",
+			maxPoints:  10,
+			wantErr:    true,
+		},
+		{
+			name:       "gap too long",
+			timestamps: []string{"2023-01-01", "2023-12-31"},
+			frequency:  "D",
+			maxPoints:  100,
+			wantErr:    true,
+		},
+	}
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := []Point{}
+			for i, v := range tt.timestamps {
+				points = append(points, Point{Timestamp: v, Value: float64(i)})
+			}
+			res, err := normalizeTimestamps(points, tt.frequency, now, tt.maxPoints)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != len(tt.want) {
+				t.Fatalf("got %d points, want %d", len(res), len(tt.want))
+			}
+			missing := make(map[int]bool)
+			for _, i := range tt.missing {
+				missing[i] = true
+			}
+			for i, p := range res {
+				if p.Timestamp != tt.want[i] {
+					t.Errorf("point %d: got %s, want %s", i, p.Timestamp, tt.want[i])
+				}
+				if p.Missing != missing[i] {
+					t.Errorf("point %d: got missing %v, want %v", i, p.Missing, missing[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeTimestampsBackDated(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	points := []Point{{Value: 1}, {Value: 2}}
+	res, err := normalizeTimestamps(points, "D", now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2023-05-30 00:00:00", "2023-05-31 00:00:00"}
+	for i, p := range res {
+		if p.Timestamp != want[i] {
+			t.Errorf("point %d: got %s, want %s", i, p.Timestamp, want[i])
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"bytes"
+	"errors"
+	"context"
+	"strings"
+	"io/ioutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+
+	"github.com/aws/smithy-go"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	stypes "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// BlobStore keeps the uploaded datasets, exported results and run manifests.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string)(io.ReadCloser, error)
+	List(ctx context.Context, prefix string)([]string, error)
+	// Delete removes key. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// URI is the location of key as passed to Forecast data sources and destinations.
+	URI(key string) string
+	// GetVersion is Get that also returns the version of the object for PutIfVersion.
+	GetVersion(ctx context.Context, key string)(io.ReadCloser, string, error)
+	// PutIfVersion replaces key only if it still has version, or creates it only if it does not
+	// exist when version is empty, and returns the new version. It returns errBlobChanged otherwise.
+	PutIfVersion(ctx context.Context, key string, body []byte, contentType string, version string)(string, error)
+}
+
+const storeTypeFile string = "file"
+
+var errBlobNotFound = errors.New("Error: No Object.")
+var errBlobChanged = errors.New("Error: Object was changed.")
+
+var blobStore BlobStore
+
+// getBlobStore returns the store selected by STORE_TYPE. "file" keeps objects under STORE_DIR;
+// anything else uses the BUCKET_NAME bucket.
+func getBlobStore(ctx context.Context) BlobStore {
+	if blobStore == nil {
+		if os.Getenv("STORE_TYPE") == storeTypeFile {
+			blobStore = &fileStore{root: os.Getenv("STORE_DIR")}
+		} else {
+			blobStore = &s3Store{bucket: os.Getenv("BUCKET_NAME")}
+		}
+	}
+	return blobStore
+}
+
+// Object layout
+
+func getDatasetKey(id string, kind datasetKind) string {
+	return bucketPath + "/" + kind.name(id) + ".csv"
+}
+
+// The export prefixes end with a slash so that one progress id or scenario name is never the
+// prefix of another's objects.
+
+func getResultPrefix(id string) string {
+	return bucketResultPath + "/" + getForecastId(id) + "/"
+}
+
+func getExplainabilityPrefix(id string) string {
+	return bucketExplainPath + "/" + getForecastId(id) + "/"
+}
+
+func getWhatIfPrefix(id string) string {
+	return bucketWhatIfPath + "/" + getForecastId(id) + "/"
+}
+
+func getScenarioPrefix(id string, name string) string {
+	return getWhatIfPrefix(id) + name + "/"
+}
+
+func getRunKey(id string) string {
+	return bucketRunPath + "/" + getForecastId(id) + ".json"
+}
+
+// getActiveRunKey marks a run that the scheduled event still has to advance.
+func getActiveRunKey(id string) string {
+	return bucketRunPath + "/" + bucketActivePath + "/" + getForecastId(id)
+}
+
+// getStoreKey is the inverse of URI for objects in the current store.
+func getStoreKey(ctx context.Context, uri string) string {
+	return strings.TrimPrefix(uri, getBlobStore(ctx).URI(""))
+}
+
+type s3Store struct {
+	bucket string
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.PutObjectInput{
+		ACL: stypes.ObjectCannedACLPrivate,
+		Bucket: aws.String(s.bucket),
+		Key: aws.String(key),
+		Body: body,
+		ContentType: aws.String(contentType),
+	}
+	if _, ok := body.(io.Seeker); !ok {
+		// Streamed bodies have no length, so upload them in parts.
+		_, err := manager.NewUploader(s3Client).Upload(ctx, input)
+		return err
+	}
+	_, err := s3Client.PutObject(ctx, input)
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string)(io.ReadCloser, error) {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	res, err := s3Client.GetObject(ctx, input)
+	var noSuchKey *stypes.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, errBlobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *s3Store) GetVersion(ctx context.Context, key string)(io.ReadCloser, string, error) {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	res, err := s3Client.GetObject(ctx, input)
+	var noSuchKey *stypes.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, "", errBlobNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	return res.Body, aws.ToString(res.ETag), nil
+}
+
+// PutIfVersion is a conditional write on the ETag.
+func (s *s3Store) PutIfVersion(ctx context.Context, key string, body []byte, contentType string, version string)(string, error) {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.PutObjectInput{
+		ACL: stypes.ObjectCannedACLPrivate,
+		Bucket: aws.String(s.bucket),
+		Key: aws.String(key),
+		Body: bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	}
+	if len(version) > 0 {
+		input.IfMatch = aws.String(version)
+	} else {
+		input.IfNoneMatch = aws.String("*")
+	}
+	res, err := s3Client.PutObject(ctx, input)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+		return "", errBlobChanged
+	} else if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.ETag), nil
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string)([]string, error) {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	keys := []string{}
+	p := s3.NewListObjectsV2Paginator(s3Client, input)
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Contents {
+			keys = append(keys, aws.ToString(v.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	if s3Client == nil {
+		s3Client = getS3Client(ctx)
+	}
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	_, err := s3Client.DeleteObject(ctx, input)
+	return err
+}
+
+func (s *s3Store) URI(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+// fileStore keeps objects as files under root, for running without AWS.
+type fileStore struct {
+	root string
+	// mu makes PutIfVersion atomic within the process.
+	mu   sync.Mutex
+}
+
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *fileStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *fileStore) Get(ctx context.Context, key string)(io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, errBlobNotFound
+	}
+	return f, err
+}
+
+// The version of a file is the SHA-256 of its content.
+func (s *fileStore) GetVersion(ctx context.Context, key string)(io.ReadCloser, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, "", errBlobNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), fileVersion(b), nil
+}
+
+func (s *fileStore) PutIfVersion(ctx context.Context, key string, body []byte, contentType string, version string)(string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := ""
+	b, err := os.ReadFile(s.path(key))
+	if err == nil {
+		current = fileVersion(b)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	if current != version {
+		return "", errBlobChanged
+	}
+	if err := s.Put(ctx, key, bytes.NewReader(body), contentType); err != nil {
+		return "", err
+	}
+	return fileVersion(body), nil
+}
+
+func fileVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *fileStore) List(ctx context.Context, prefix string)([]string, error) {
+	keys := []string{}
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *fileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) URI(key string) string {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		root = s.root
+	}
+	return "file://" + filepath.ToSlash(root) + "/" + key
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"log"
+	"time"
+	"regexp"
+	"context"
+	"strconv"
+	"strings"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/forecast"
+	ftypes "github.com/aws/aws-sdk-go-v2/service/forecast/types"
+)
+
+// Scenario is a what-if change to one related time series attribute of a run's forecast. The
+// attribute is transformed with Operation and Value at the timestamps from Start to End, inclusive,
+// and only for ItemID when these are set.
+type Scenario struct {
+	Name      string  `json:"name"`
+	Attribute string  `json:"attribute"`
+	Operation string  `json:"operation"`
+	Value     float64 `json:"value"`
+	Start     string  `json:"start,omitempty"`
+	End       string  `json:"end,omitempty"`
+	ItemID    string  `json:"item_id,omitempty"`
+}
+
+// ScenarioData is one forecast point with the quantiles of the run's forecast and of the scenario.
+type ScenarioData struct {
+	ItemID   string             `json:"item_id"`
+	Date     string             `json:"date"`
+	Baseline map[string]float64 `json:"baseline"`
+	Scenario map[string]float64 `json:"scenario"`
+}
+
+// ScenarioResult is a scenario and its forecast next to the baseline, grouped by item_id.
+type ScenarioResult struct {
+	Scenario *Scenario                 `json:"scenario"`
+	Result   map[string][]ScenarioData `json:"result"`
+}
+
+// Scenario names become part of Forecast resource names, which are at most 63 characters.
+var scenarioNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,39}$`)
+
+var scenarioOperations = map[string]ftypes.Operation{
+	"ADD":      ftypes.OperationAdd,
+	"SUBTRACT": ftypes.OperationSubtract,
+	"MULTIPLY": ftypes.OperationMultiply,
+	"DIVIDE":   ftypes.OperationDivide,
+}
+
+// getScenario reads a scenario from the name, attribute, operation, value, start, end and item_id
+// parameters.
+func getScenario(d map[string]string)(*Scenario, error) {
+	s := &Scenario{
+		Name:      d["name"],
+		Attribute: d["attribute"],
+		Operation: strings.ToUpper(d["operation"]),
+		ItemID:    d["item_id"],
+	}
+	if !scenarioNamePattern.MatchString(s.Name) {
+		return nil, validationError("Invalid Scenario Name.")
+	}
+	if !constant.AttributeNamePattern.MatchString(s.Attribute) {
+		return nil, validationError("Invalid Attribute.")
+	}
+	op, ok := scenarioOperations[s.Operation]
+	if !ok {
+		return nil, validationError("Invalid Operation.")
+	}
+	v, err := strconv.ParseFloat(d["value"], 64)
+	if err != nil || !isFinite(v) || (op == ftypes.OperationDivide && v == 0) {
+		return nil, validationError("Invalid Value.")
+	}
+	s.Value = v
+	var start, end time.Time
+	if v, ok := d["start"]; ok && len(v) > 0 {
+		if start, err = parseTimestamp(v); err != nil {
+			return nil, err
+		}
+		s.Start = start.Format(layout4)
+	}
+	if v, ok := d["end"]; ok && len(v) > 0 {
+		if end, err = parseTimestamp(v); err != nil {
+			return nil, err
+		}
+		s.End = end.Format(layout4)
+	}
+	if len(s.Start) > 0 && len(s.End) > 0 && end.Before(start) {
+		return nil, validationError("Start must not be after End.")
+	}
+	return s, nil
+}
+
+func getScenarioName(id string, name string) string {
+	return getForecastId(id) + "_" + name
+}
+
+// arnKey is the key of a scenario's resource in Run.Arns.
+func (s *Scenario) arnKey(kind string) string {
+	return kind + "/" + s.Name
+}
+
+func findScenario(run *Run, name string) *Scenario {
+	for i := range run.Scenarios {
+		if run.Scenarios[i].Name == name {
+			return &run.Scenarios[i]
+		}
+	}
+	return nil
+}
+
+// transformations converts a scenario to the what-if forecast request. Forecast compares timestamps
+// strictly, so the inclusive range is widened by a second on both ends.
+func (s *Scenario) transformations(itemField string) []ftypes.TimeSeriesTransformation {
+	conditions := []ftypes.TimeSeriesCondition{}
+	if len(s.ItemID) > 0 {
+		conditions = append(conditions, ftypes.TimeSeriesCondition{
+			AttributeName:  aws.String(itemField),
+			AttributeValue: aws.String(s.ItemID),
+			Condition:      ftypes.ConditionEquals,
+		})
+	}
+	if t, err := time.Parse(layout4, s.Start); err == nil {
+		conditions = append(conditions, ftypes.TimeSeriesCondition{
+			AttributeName:  aws.String(constant.TimestampField),
+			AttributeValue: aws.String(t.Add(-time.Second).Format(layout4)),
+			Condition:      ftypes.ConditionGreaterThan,
+		})
+	}
+	if t, err := time.Parse(layout4, s.End); err == nil {
+		conditions = append(conditions, ftypes.TimeSeriesCondition{
+			AttributeName:  aws.String(constant.TimestampField),
+			AttributeValue: aws.String(t.Add(time.Second).Format(layout4)),
+			Condition:      ftypes.ConditionLessThan,
+		})
+	}
+	return []ftypes.TimeSeriesTransformation{
+		{
+			Action: &ftypes.Action{
+				AttributeName: aws.String(s.Attribute),
+				Operation:     scenarioOperations[s.Operation],
+				Value:         aws.Float64(s.Value),
+			},
+			TimeSeriesConditions: conditions,
+		},
+	}
+}
+
+func createWhatIfAnalysis(ctx context.Context, id string, forecastArn string)(string, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	input := &forecast.CreateWhatIfAnalysisInput{
+		WhatIfAnalysisName: aws.String(getForecastId(id)),
+		ForecastArn: aws.String(forecastArn),
+	}
+	res, err := forecastClient.CreateWhatIfAnalysis(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.WhatIfAnalysisArn), nil
+}
+
+func createWhatIfForecast(ctx context.Context, id string, whatIfAnalysisArn string, s *Scenario, itemField string)(string, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	input := &forecast.CreateWhatIfForecastInput{
+		WhatIfForecastName: aws.String(getScenarioName(id, s.Name)),
+		WhatIfAnalysisArn: aws.String(whatIfAnalysisArn),
+		TimeSeriesTransformations: s.transformations(itemField),
+	}
+	res, err := forecastClient.CreateWhatIfForecast(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.WhatIfForecastArn), nil
+}
+
+func createWhatIfForecastExport(ctx context.Context, id string, s *Scenario, whatIfForecastArn string, path string, roleArn string)(string, error) {
+	if forecastClient == nil {
+		forecastClient = getForecastClient(ctx)
+	}
+
+	input := &forecast.CreateWhatIfForecastExportInput{
+		WhatIfForecastExportName: aws.String(getScenarioName(id, s.Name)),
+		WhatIfForecastArns: []string{whatIfForecastArn},
+		Destination: &ftypes.DataDestination{
+			S3Config: &ftypes.S3Config{
+				Path: aws.String(path),
+				RoleArn: aws.String(roleArn),
+			},
+		},
+	}
+	res, err := forecastClient.CreateWhatIfForecastExport(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.WhatIfForecastExportArn), nil
+}
+
+// defineScenario records a new scenario on a run and starts it. Defining the same scenario again
+// only checks it; a different scenario under a used name is a conflict.
+func defineScenario(ctx context.Context, id string, s *Scenario)(string, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return "", conflictError("Run is deleted.")
+	}
+	// Forecast only runs what-if analyses on forecasts of an AutoPredictor.
+	if !isAutoPredictor(run) {
+		return "", validationError("Run was not started with explain.")
+	}
+	hasRelated := false
+	for _, k := range getDatasetKinds(run) {
+		hasRelated = hasRelated || k.Type == ftypes.DatasetTypeRelatedTimeSeries
+	}
+	if !hasRelated {
+		return "", validationError("Run has no related time series.")
+	}
+	if v := findScenario(run, s.Name); v == nil {
+		run.Scenarios = append(run.Scenarios, *s)
+	} else if *v != *s {
+		return "", conflictError("Scenario " + s.Name + " already exists.")
+	}
+	res, err := checkScenario(ctx, run, findScenario(run, s.Name))
+	if err != nil {
+		return "", err
+	}
+	if err := saveActiveRun(ctx, run); err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// checkScenarioStage runs checkScenario for the checkscenario action and records what it found.
+func checkScenarioStage(ctx context.Context, id string, name string)(string, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return "", conflictError("Run is deleted.")
+	}
+	s := findScenario(run, name)
+	if s == nil {
+		return "", notFoundError("No Scenario.")
+	}
+	res, err := checkScenario(ctx, run, s)
+	if err != nil {
+		return "", err
+	}
+	if err := saveActiveRun(ctx, run); err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// checkScenario advances a scenario through the run's what-if analysis, the scenario's what-if
+// forecast and its export. It reports the status of the first of them that is not ACTIVE, and the
+// export status once the others are.
+func checkScenario(ctx context.Context, run *Run, s *Scenario)(string, error) {
+	id := run.ID
+	// GetWhatIfAnalysis
+	wia, found, err := getWhatIfAnalysis(ctx, run)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
+		// CreateWhatIfAnalysis
+		fct, found, err := getForecast(ctx, run)
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		if !found {
+			return "", notFoundError("No Forecast.")
+		}
+		if aws.ToString(fct.Status) != "ACTIVE" {
+			return "", conflictError("Forecast is not active.")
+		}
+		arn, err := createWhatIfAnalysis(ctx, id, aws.ToString(fct.ForecastArn))
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		run.Arns[arnWhatIf] = arn
+		return "Start", nil
+	}
+	run.Arns[arnWhatIf] = aws.ToString(wia.WhatIfAnalysisArn)
+	if aws.ToString(wia.Status) != "ACTIVE" {
+		return aws.ToString(wia.Status), nil
+	}
+
+	// GetWhatIfForecast
+	wif, found, err := getWhatIfForecast(ctx, run, s)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
+		// CreateWhatIfForecast
+		schema, err := constant.GetSchema()
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		arn, err := createWhatIfForecast(ctx, id, aws.ToString(wia.WhatIfAnalysisArn), s, schema.ItemField())
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		run.Arns[s.arnKey(arnWhatIfForecast)] = arn
+		return "Start", nil
+	}
+	run.Arns[s.arnKey(arnWhatIfForecast)] = aws.ToString(wif.WhatIfForecastArn)
+	if aws.ToString(wif.Status) != "ACTIVE" {
+		return aws.ToString(wif.Status), nil
+	}
+
+	// GetWhatIfForecastExport
+	exp, found, err := getWhatIfForecastExport(ctx, run, s)
+	if err != nil {
+		log.Print(err)
+		return "", err
+	}
+	if !found {
+		// CreateWhatIfForecastExport
+		path := getBlobStore(ctx).URI(getScenarioPrefix(id, s.Name))
+		arn, err := createWhatIfForecastExport(ctx, id, s, aws.ToString(wif.WhatIfForecastArn), path, os.Getenv("FORECAST_ROLE_ARN"))
+		if err != nil {
+			log.Print(err)
+			return "", err
+		}
+		run.Arns[s.arnKey(arnWhatIfExport)] = arn
+		return "Start", nil
+	}
+	run.Arns[s.arnKey(arnWhatIfExport)] = aws.ToString(exp.WhatIfForecastExportArn)
+	return aws.ToString(exp.Status), nil
+}
+
+// getScenarioResult pairs the exported scenario forecast with the run's own result, point by point.
+func getScenarioResult(ctx context.Context, id string, name string)(*ScenarioResult, error) {
+	run, err := loadRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == runStatusDeleting || run.Status == runStatusDeleted {
+		return nil, conflictError("Run is deleted.")
+	}
+	s := findScenario(run, name)
+	if s == nil {
+		return nil, notFoundError("No Scenario.")
+	}
+	baseline, err := readResult(ctx, run, getResultPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+	if baseline == nil {
+		return nil, notFoundError("No ObjectKey.")
+	}
+	whatIf, err := readResult(ctx, run, getScenarioPrefix(id, name))
+	if err != nil {
+		return nil, err
+	}
+	if whatIf == nil {
+		return nil, notFoundError("No Scenario Result.")
+	}
+	scenario := make(map[string]map[string]float64)
+	for _, item := range whatIf {
+		for _, v := range item {
+			scenario[v.ItemID + "/" + v.Date] = v.Quantiles
+		}
+	}
+	res := &ScenarioResult{Scenario: s, Result: make(map[string][]ScenarioData)}
+	for itemId, rows := range baseline {
+		for _, v := range rows {
+			q, ok := scenario[v.ItemID + "/" + v.Date]
+			if !ok {
+				q = map[string]float64{}
+			}
+			res.Result[itemId] = append(res.Result[itemId], ScenarioData{ItemID: v.ItemID, Date: v.Date, Baseline: v.Quantiles, Scenario: q})
+		}
+	}
+	return res, nil
+}
+
+// Local what-if
+
+// localScenarioRows applies what-if transformations to the rows of a local forecast. The local model
+// does not use related time series, so every item's target is regressed on each attribute over the
+// history and a changed attribute moves all quantiles by slope × change.
+func localScenarioRows(rows [][]string, itemField string, target map[string]*localSeries, related map[string]map[string]*localSeries, transformations []ftypes.TimeSeriesTransformation)([][]string, error) {
+	res := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		itemId := row[0]
+		t, err := parseTimestamp(row[1])
+		if err != nil {
+			return nil, err
+		}
+		delta := 0.0
+		for _, v := range transformations {
+			if v.Action == nil {
+				continue
+			}
+			name := aws.ToString(v.Action.AttributeName)
+			s, ok := related[itemId][name]
+			if !ok {
+				return nil, fmt.Errorf("Error: %s", "No Related Attribute " + name + ".")
+			}
+			x, ok := localValueAt(s, t)
+			if !ok || !localConditionsMatch(v.TimeSeriesConditions, itemField, itemId, t, related[itemId]) {
+				continue
+			}
+			delta += localSlope(s, target[itemId]) * (localApply(v.Action.Operation, x, aws.ToFloat64(v.Action.Value)) - x)
+		}
+		out := append([]string{}, row[:2]...)
+		for _, v := range row[2:] {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, strconv.FormatFloat(f + delta, 'f', -1, 64))
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+func localValueAt(s *localSeries, t time.Time)(float64, bool) {
+	for i, v := range s.timestamps {
+		if v.Unix() == t.Unix() {
+			return s.values[i], true
+		}
+	}
+	return 0, false
+}
+
+func localApply(op ftypes.Operation, x float64, v float64) float64 {
+	switch op {
+	case ftypes.OperationAdd :
+		return x + v
+	case ftypes.OperationSubtract :
+		return x - v
+	case ftypes.OperationMultiply :
+		return x * v
+	case ftypes.OperationDivide :
+		return x / v
+	}
+	return x
+}
+
+// localConditionsMatch checks conditions on the item field, the timestamp or a related attribute at t.
+func localConditionsMatch(conditions []ftypes.TimeSeriesCondition, itemField string, itemId string, t time.Time, attrs map[string]*localSeries) bool {
+	for _, c := range conditions {
+		name, value := aws.ToString(c.AttributeName), aws.ToString(c.AttributeValue)
+		cmp := 0
+		switch name {
+		case itemField :
+			cmp = strings.Compare(itemId, value)
+		case constant.TimestampField :
+			ct, err := parseTimestamp(value)
+			if err != nil {
+				return false
+			}
+			cmp = int(t.Sub(ct) / time.Second)
+		default :
+			s, ok := attrs[name]
+			if !ok {
+				return false
+			}
+			x, ok := localValueAt(s, t)
+			f, err := strconv.ParseFloat(value, 64)
+			if !ok || err != nil {
+				return false
+			}
+			if x < f {
+				cmp = -1
+			} else if x > f {
+				cmp = 1
+			}
+		}
+		switch c.Condition {
+		case ftypes.ConditionEquals :
+			if cmp != 0 {
+				return false
+			}
+		case ftypes.ConditionNotEquals :
+			if cmp == 0 {
+				return false
+			}
+		case ftypes.ConditionLessThan :
+			if cmp >= 0 {
+				return false
+			}
+		case ftypes.ConditionGreaterThan :
+			if cmp <= 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// localSlope is the least squares slope of the target on an attribute over their shared timestamps,
+// 0 when the attribute is constant.
+func localSlope(s *localSeries, target *localSeries) float64 {
+	if target == nil {
+		return 0
+	}
+	values := make(map[int64]float64)
+	for i, v := range target.timestamps {
+		values[v.Unix()] = target.values[i]
+	}
+	xs, ys := []float64{}, []float64{}
+	for i, v := range s.timestamps {
+		if y, ok := values[v.Unix()]; ok {
+			xs = append(xs, s.values[i])
+			ys = append(ys, y)
+		}
+	}
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	mx, my := 0.0, 0.0
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	sxy, sxx := 0.0, 0.0
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if sxx == 0 {
+		return 0
+	}
+	return sxy / sxx
+}
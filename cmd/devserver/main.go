@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"log"
+	"net"
+	"flag"
+	"time"
+	"strings"
+	"net/rpc"
+	"os/exec"
+	"os/signal"
+	"net/http"
+	"io/ioutil"
+	"path/filepath"
+	"unicode/utf8"
+	"encoding/json"
+	"encoding/base64"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// function is a Lambda handler binary started in RPC mode (_LAMBDA_SERVER_PORT), the same way the
+// Go runtime used to invoke handlers.
+type function struct {
+	name   string
+	cmd    *exec.Cmd
+	client *rpc.Client
+}
+
+const apiPath        string = "/api"
+const invokeTimeout  time.Duration = 5 * time.Minute
+
+func freePort()(string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	return port, err
+}
+
+func startFunction(name string, root string, pkg string, binDir string, env []string)(*function, error) {
+	bin := filepath.Join(binDir, name)
+	build := exec.Command("go", "build", "-o", bin, pkg)
+	build.Dir = root
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = filepath.Join(root, pkg)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, "_LAMBDA_SERVER_PORT=" + port)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	f := &function{name: name, cmd: cmd}
+	for i := 0; i < 50; i++ {
+		if f.client, err = rpc.Dial("tcp", "127.0.0.1:" + port); err == nil {
+			log.Printf("%s listening on %s\n", name, port)
+			return f, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	f.stop()
+	return nil, err
+}
+
+func (f *function) stop() {
+	if f.client != nil {
+		f.client.Close()
+	}
+	if f.cmd.Process != nil {
+		f.cmd.Process.Kill()
+		f.cmd.Wait()
+	}
+}
+
+func (f *function) invoke(request events.APIGatewayProxyRequest)(events.APIGatewayProxyResponse, error) {
+	var response events.APIGatewayProxyResponse
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	deadline := time.Now().Add(invokeTimeout)
+	req := &messages.InvokeRequest{
+		Payload:   payload,
+		RequestId: request.RequestContext.RequestID,
+		Deadline: messages.InvokeRequest_Timestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+	}
+	var res messages.InvokeResponse
+	if err := f.client.Call("Function.Invoke", req, &res); err != nil {
+		return response, err
+	}
+	if res.Error != nil {
+		return response, res.Error
+	}
+	err = json.Unmarshal(res.Payload, &response)
+	return response, err
+}
+
+// toProxyRequest converts an HTTP request into the event API Gateway would send.
+func toProxyRequest(r *http.Request)(events.APIGatewayProxyRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	request := events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         make(map[string]string),
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: r.URL.Query(),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  time.Now().Format("20060102150405.000000000"),
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+		},
+	}
+	for k, v := range r.Header {
+		request.Headers[k] = strings.Join(v, ",")
+	}
+	for k, v := range r.URL.Query() {
+		request.QueryStringParameters[k] = v[len(v) - 1]
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		request.RequestContext.Identity.SourceIP = host
+	}
+	if utf8.Valid(body) && !strings.HasPrefix(r.Header.Get("Content-Type"), "application/octet-stream") {
+		request.Body = string(body)
+	} else {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.IsBase64Encoded = true
+	}
+	return request, nil
+}
+
+func writeProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for k, v := range response.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, v := range response.MultiValueHeaders {
+		for _, v_ := range v {
+			w.Header().Add(k, v_)
+		}
+	}
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		if b, err := base64.StdEncoding.DecodeString(response.Body); err == nil {
+			body = b
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	w.Write(body)
+}
+
+func handle(f *function, method string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		request, err := toProxyRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err := f.invoke(request)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Printf("%s %s %d\n", r.Method, r.URL.Path, response.StatusCode)
+		writeProxyResponse(w, response)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	root := flag.String("root", ".", "repository root")
+	backend := flag.String("backend", "local", "FORECAST_BACKEND of the API function")
+	storeDir := flag.String("store", "", "STORE_DIR of the API function (default: a temporary directory)")
+	flag.Parse()
+
+	binDir, err := ioutil.TempDir("", "devserver")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+	if len(*storeDir) == 0 {
+		*storeDir = filepath.Join(binDir, "store")
+	} else if *storeDir, err = filepath.Abs(*storeDir); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("store: " + *storeDir)
+
+	page, err := startFunction("page", *root, ".", binDir, []string{"API_PATH=" + apiPath})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer page.stop()
+	api, err := startFunction("api", *root, "./api", binDir, []string{
+		"FORECAST_BACKEND=" + *backend,
+		"STORE_TYPE=file",
+		"STORE_DIR=" + *storeDir,
+	})
+	if err != nil {
+		page.stop()
+		log.Fatal(err)
+	}
+	defer api.stop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handle(page, http.MethodGet))
+	mux.HandleFunc(apiPath, handle(api, http.MethodPost))
+	server := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		<-c
+		server.Close()
+	}()
+	log.Println("http://" + *addr + "/")
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Print(err)
+	}
+}
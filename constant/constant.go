@@ -0,0 +1,91 @@
+package constant
+
+import (
+	"os"
+	"fmt"
+	"embed"
+	"strconv"
+	"encoding/json"
+)
+
+//go:embed limits.json
+var limitsFS embed.FS
+
+// Limits bounds the number of points per item that senddata accepts.
+//   MinPoints       minimum per frequency, "default" for frequencies not listed
+//   MaxPoints       maximum per item
+//   HorizonMultiple an item needs at least HorizonMultiple * horizon points
+//   StreamPoints    uploads with more points than this in total are streamed
+type Limits struct {
+	MinPoints       map[string]int `json:"min_points"`
+	MaxPoints       int            `json:"max_points"`
+	HorizonMultiple int            `json:"horizon_multiple"`
+	StreamPoints    int            `json:"stream_points"`
+}
+
+var limits *Limits
+
+// GetLimits reads limits.json once. LIMIT_MIN_POINTS, LIMIT_MAX_POINTS, LIMIT_HORIZON_MULTIPLE and
+// LIMIT_STREAM_POINTS override the file; LIMIT_MIN_POINTS applies to every frequency.
+func GetLimits()(*Limits, error) {
+	if limits != nil {
+		return limits, nil
+	}
+	b, err := limitsFS.ReadFile("limits.json")
+	if err != nil {
+		return nil, err
+	}
+	l := &Limits{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, err
+	}
+	if l.MinPoints == nil {
+		l.MinPoints = make(map[string]int)
+	}
+	if v, ok, err := getEnvInt("LIMIT_MIN_POINTS"); err != nil {
+		return nil, err
+	} else if ok {
+		l.MinPoints = map[string]int{"default": v}
+	}
+	if v, ok, err := getEnvInt("LIMIT_MAX_POINTS"); err != nil {
+		return nil, err
+	} else if ok {
+		l.MaxPoints = v
+	}
+	if v, ok, err := getEnvInt("LIMIT_HORIZON_MULTIPLE"); err != nil {
+		return nil, err
+	} else if ok {
+		l.HorizonMultiple = v
+	}
+	if v, ok, err := getEnvInt("LIMIT_STREAM_POINTS"); err != nil {
+		return nil, err
+	} else if ok {
+		l.StreamPoints = v
+	}
+	limits = l
+	return limits, nil
+}
+
+// MinPointsFor is the minimum number of points per item for a frequency and horizon.
+func (l *Limits) MinPointsFor(frequency string, horizon int) int {
+	mn, ok := l.MinPoints[frequency]
+	if !ok {
+		mn = l.MinPoints["default"]
+	}
+	if h := l.HorizonMultiple * horizon; h > mn {
+		mn = h
+	}
+	return mn
+}
+
+func getEnvInt(key string)(int, bool, error) {
+	s := os.Getenv(key)
+	if len(s) < 1 {
+		return 0, false, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, false, fmt.Errorf("Error: %s", "Invalid " + key + ".")
+	}
+	return v, true, nil
+}
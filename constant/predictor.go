@@ -0,0 +1,64 @@
+package constant
+
+import (
+	"regexp"
+	"strings"
+	"strconv"
+)
+
+// MaxForecastTypes is the number of forecast types a predictor accepts.
+const MaxForecastTypes int = 5
+
+// MaxBacktestWindows is the largest NumberOfBacktestWindows of a predictor.
+const MaxBacktestWindows int = 5
+
+// algorithmArns are the Forecast algorithms a predictor can be trained with, by lower case name.
+var algorithmArns = map[string]string{
+	"arima":        "arn:aws:forecast:::algorithm/ARIMA",
+	"ets":          "arn:aws:forecast:::algorithm/ETS",
+	"npts":         "arn:aws:forecast:::algorithm/NPTS",
+	"prophet":      "arn:aws:forecast:::algorithm/Prophet",
+	"deep_ar_plus": "arn:aws:forecast:::algorithm/Deep_AR_Plus",
+	"cnn_qr":       "arn:aws:forecast:::algorithm/CNN-QR",
+}
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// AlgorithmArn returns the ARN of an algorithm name. "DeepAR+" and "CNN-QR" are accepted as well as
+// deep_ar_plus and cnn_qr.
+func AlgorithmArn(name string)(string, bool) {
+	key := strings.ToLower(name)
+	key = strings.Replace(key, "deepar+", "deep_ar_plus", 1)
+	key = strings.Replace(key, "-", "_", -1)
+	arn, ok := algorithmArns[key]
+	return arn, ok
+}
+
+// ParseForecastTypes splits a comma separated list of quantiles (0.01 to 0.99) and "mean".
+func ParseForecastTypes(s string)([]string, bool) {
+	types := []string{}
+	seen := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "mean" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0.01 || f > 0.99 || !strings.HasPrefix(v, "0.") || len(v) > 4 {
+				return nil, false
+			}
+		}
+		if seen[v] {
+			return nil, false
+		}
+		seen[v] = true
+		types = append(types, v)
+	}
+	if len(types) > MaxForecastTypes {
+		return nil, false
+	}
+	return types, true
+}
+
+// IsCountryCode reports whether s looks like the two letter country code of a holiday calendar.
+func IsCountryCode(s string) bool {
+	return countryCodePattern.MatchString(s)
+}
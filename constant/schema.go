@@ -0,0 +1,119 @@
+package constant
+
+import (
+	"os"
+	"fmt"
+	"embed"
+	"regexp"
+	"encoding/json"
+)
+
+//go:embed schema.json
+var schemaFS embed.FS
+
+// TimestampField is the timestamp attribute of every time series domain.
+const TimestampField string = "timestamp"
+
+// Attribute is one column of a dataset schema.
+type Attribute struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Schema is the domain and the target time series attributes. The uploaded CSV has one column per
+// attribute, in the order of Attributes. The attributes are exactly the item, timestamp and target
+// fields of the domain, since the API has no values for any other column; a schema only chooses
+// their order and whether the target is a float or an integer.
+type Schema struct {
+	Domain     string      `json:"domain"`
+	Attributes []Attribute `json:"attributes"`
+}
+
+// domainFields are the item and target attribute names that each Forecast domain requires.
+var domainFields = map[string][2]string{
+	"RETAIL":             {"item_id", "demand"},
+	"CUSTOM":             {"item_id", "target_value"},
+	"INVENTORY_PLANNING": {"item_id", "demand"},
+	"EC2_CAPACITY":       {"instance_type", "number_of_instances"},
+	"WORK_FORCE":         {"workforce_type", "workforce_demand"},
+	"WEB_TRAFFIC":        {"item_id", "value"},
+	"METRICS":            {"metric_name", "metric_value"},
+}
+
+var attributeTypes = map[string]bool{"string": true, "integer": true, "float": true, "timestamp": true, "geolocation": true}
+
+// AttributeNamePattern is the attribute name Forecast accepts in any dataset schema.
+var AttributeNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,62}$`)
+
+var schema *Schema
+
+// GetSchema reads schema.json once, or the file named by SCHEMA_FILE, and checks it against its domain.
+func GetSchema()(*Schema, error) {
+	if schema != nil {
+		return schema, nil
+	}
+	var b []byte
+	var err error
+	if path := os.Getenv("SCHEMA_FILE"); len(path) > 0 {
+		b, err = os.ReadFile(path)
+	} else {
+		b, err = schemaFS.ReadFile("schema.json")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	schema = s
+	return schema, nil
+}
+
+// validate accepts exactly the item, timestamp and target attributes of the domain, in any order,
+// since those are the only columns the API can fill.
+func (s *Schema) validate() error {
+	fields, ok := domainFields[s.Domain]
+	if !ok {
+		return fmt.Errorf("Error: %s", "Invalid Schema Domain " + s.Domain + ".")
+	}
+	types := make(map[string]string)
+	for _, v := range s.Attributes {
+		if !AttributeNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("Error: %s", "Invalid Schema Attribute " + v.Name + ".")
+		}
+		if !attributeTypes[v.Type] {
+			return fmt.Errorf("Error: %s", "Invalid Schema Attribute Type " + v.Type + ".")
+		}
+		if _, ok := types[v.Name]; ok {
+			return fmt.Errorf("Error: %s", "Duplicate Schema Attribute " + v.Name + ".")
+		}
+		types[v.Name] = v.Type
+	}
+	if len(s.Attributes) != 3 {
+		return fmt.Errorf("Error: %s", "Schema of " + s.Domain + " must have exactly " + fields[0] + ", " + TimestampField + " and " + fields[1] + "; other attributes are not supported.")
+	}
+	if t, ok := types[fields[0]]; !ok || t != "string" {
+		return fmt.Errorf("Error: %s", "Schema needs string attribute " + fields[0] + ".")
+	}
+	if t, ok := types[TimestampField]; !ok || t != "timestamp" {
+		return fmt.Errorf("Error: %s", "Schema needs timestamp attribute " + TimestampField + ".")
+	}
+	if t, ok := types[fields[1]]; !ok || (t != "float" && t != "integer") {
+		return fmt.Errorf("Error: %s", "Schema needs float or integer attribute " + fields[1] + ".")
+	}
+	return nil
+}
+
+// ItemField is the attribute that identifies an item, item_id for most domains.
+func (s *Schema) ItemField() string {
+	return domainFields[s.Domain][0]
+}
+
+// TargetField is the attribute that is forecast.
+func (s *Schema) TargetField() string {
+	return domainFields[s.Domain][1]
+}
@@ -6,7 +6,10 @@ require (
 	github.com/aws/aws-lambda-go latest
 	github.com/aws/aws-sdk-go-v2 latest
 	github.com/aws/aws-sdk-go-v2/config latest
+	github.com/aws/aws-sdk-go-v2/feature/s3/manager latest
 	github.com/aws/aws-sdk-go-v2/service/forecast latest
+	github.com/aws/aws-sdk-go-v2/service/forecastquery latest
 	github.com/aws/aws-sdk-go-v2/service/s3 latest
+	github.com/aws/smithy-go latest
 	github.com/jszwec/csvutil latest
 )
@@ -10,11 +10,14 @@ import (
 	"html/template"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
 )
 
 type PageData struct {
-	Title   string
-	ApiPath string
+	Title     string
+	ApiPath   string
+	MinPoints int
+	MaxPoints int
 }
 
 type Response events.APIGatewayProxyResponse
@@ -38,6 +41,13 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	fw := io.Writer(buf)
 	dat.Title = title
 	dat.ApiPath = os.Getenv("API_PATH")
+	limits, err := constant.GetLimits()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The page sends daily data with the default horizon.
+	dat.MinPoints = limits.MinPointsFor("D", 10)
+	dat.MaxPoints = limits.MaxPoints
 	tmp = template.Must(template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/index.html", "templates/view.html", "templates/header.html"))
 	if e := tmp.ExecuteTemplate(fw, "base", dat); e != nil {
 		log.Fatal(e)
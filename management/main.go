@@ -2,12 +2,17 @@ package main
 
 import (
 	"log"
+	"errors"
 	"flag"
 	"time"
 	"bytes"
 	"strconv"
+	"strings"
+	"io/ioutil"
 	"encoding/json"
+	"github.com/tanaka-takurou/serverless-forecast-page-go/constant"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/forecastservice"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -19,6 +24,17 @@ const layout2        string = "20060102150405"
 const layout3        string = "2006-01-02 00:00:00"
 const bucketRegion   string = "ap-northeast-1"
 const forecastRegion string = "ap-northeast-1"
+const idPrefix       string = "id"
+const deleteInterval time.Duration = 10 * time.Second
+const deleteTimeout  time.Duration = time.Hour
+
+// Predictor options, given before the command: management -algorithm ETS createPredictor <Name> <DatasetGroupArn>
+var algorithm = flag.String("algorithm", "", "ARIMA, ETS, NPTS, Prophet, DeepAR+ or CNN-QR; AutoML when empty")
+var forecastTypes = flag.String("forecast_types", "", "comma separated quantiles and mean, e.g. 0.1,0.5,0.9,mean")
+var holidays = flag.String("holidays", "", "country code of the holiday calendar, e.g. JP")
+var backtestWindows = flag.Int("backtest_windows", 0, "number of backtest windows, 1 to 5")
+var backtestOffset = flag.Int("backtest_offset", 0, "backtest window offset, at least the horizon")
+var horizon = flag.Int("horizon", 10, "forecast horizon")
 
 func getForecastservice() *forecastservice.ForecastService {
 	return forecastservice.New(session.New(), &aws.Config{
@@ -40,10 +56,14 @@ func getSession()(*session.Session, error) {
 
 func createDatasetGroup(name string) error {
 	svc := getForecastservice()
+	schema, err := constant.GetSchema()
+	if err != nil {
+		return err
+	}
 
 	input := &forecastservice.CreateDatasetGroupInput{
 		DatasetGroupName: aws.String(name),
-		Domain: aws.String("CUSTOM"),
+		Domain: aws.String(schema.Domain),
 	}
 	res, err := svc.CreateDatasetGroup(input)
 	if err != nil {
@@ -55,27 +75,25 @@ func createDatasetGroup(name string) error {
 
 func createDataset(name string) error {
 	svc := getForecastservice()
+	schema, err := constant.GetSchema()
+	if err != nil {
+		return err
+	}
+	attributes := []*forecastservice.SchemaAttribute{}
+	for _, v := range schema.Attributes {
+		attributes = append(attributes, &forecastservice.SchemaAttribute{
+			AttributeName: aws.String(v.Name),
+			AttributeType: aws.String(v.Type),
+		})
+	}
 
 	input := &forecastservice.CreateDatasetInput{
 		DatasetName: aws.String(name),
 		DataFrequency: aws.String("D"),
 		DatasetType: aws.String("TARGET_TIME_SERIES"),
-		Domain: aws.String("CUSTOM"),
+		Domain: aws.String(schema.Domain),
 		Schema: &forecastservice.Schema{
-			Attributes: []*forecastservice.SchemaAttribute{
-				{
-					AttributeName: aws.String("item_id"),
-					AttributeType: aws.String("string"),
-				},
-				{
-					AttributeName: aws.String("timestamp"),
-					AttributeType: aws.String("timestamp"),
-				},
-				{
-					AttributeName: aws.String("target_value"),
-					AttributeType: aws.String("float"),
-				},
-			},
+			Attributes: attributes,
 		},
 	}
 	res, err := svc.CreateDataset(input)
@@ -114,6 +132,13 @@ func createForecast(name string, predictorArn string) error {
 		ForecastName: aws.String(name),
 		PredictorArn: aws.String(predictorArn),
 	}
+	if len(*forecastTypes) > 0 {
+		types, ok := constant.ParseForecastTypes(*forecastTypes)
+		if !ok {
+			return errors.New("Error: Invalid Forecast Types.")
+		}
+		input.ForecastTypes = aws.StringSlice(types)
+	}
 	res, err := svc.CreateForecast(input)
 	if err != nil {
 		return err
@@ -148,8 +173,7 @@ func createPredictor(name string, datasetGroupArn string) error {
 
 	input := &forecastservice.CreatePredictorInput{
 		PredictorName: aws.String(name),
-		PerformAutoML: aws.Bool(true),
-		ForecastHorizon: aws.Int64(10),
+		ForecastHorizon: aws.Int64(int64(*horizon)),
 		InputDataConfig: &forecastservice.InputDataConfig{
 			DatasetGroupArn: aws.String(datasetGroupArn),
 		},
@@ -157,6 +181,48 @@ func createPredictor(name string, datasetGroupArn string) error {
 			ForecastFrequency: aws.String("D"),
 		},
 	}
+	if len(*algorithm) > 0 {
+		arn, ok := constant.AlgorithmArn(*algorithm)
+		if !ok {
+			return errors.New("Error: Invalid Algorithm.")
+		}
+		input.AlgorithmArn = aws.String(arn)
+	} else {
+		input.PerformAutoML = aws.Bool(true)
+	}
+	if len(*forecastTypes) > 0 {
+		types, ok := constant.ParseForecastTypes(*forecastTypes)
+		if !ok {
+			return errors.New("Error: Invalid Forecast Types.")
+		}
+		input.ForecastTypes = aws.StringSlice(types)
+	}
+	if len(*holidays) > 0 {
+		if !constant.IsCountryCode(*holidays) {
+			return errors.New("Error: Invalid Holidays.")
+		}
+		input.InputDataConfig.SupplementaryFeatures = []*forecastservice.SupplementaryFeature{
+			{
+				Name: aws.String("holiday"),
+				Value: aws.String(*holidays),
+			},
+		}
+	}
+	if *backtestWindows != 0 || *backtestOffset != 0 {
+		input.EvaluationParameters = &forecastservice.EvaluationParameters{}
+		if *backtestWindows != 0 {
+			if *backtestWindows < 1 || *backtestWindows > constant.MaxBacktestWindows {
+				return errors.New("Error: Invalid Backtest Windows.")
+			}
+			input.EvaluationParameters.NumberOfBacktestWindows = aws.Int64(int64(*backtestWindows))
+		}
+		if *backtestOffset != 0 {
+			if *backtestOffset < *horizon {
+				return errors.New("Error: Backtest Offset must be at least Horizon.")
+			}
+			input.EvaluationParameters.BackTestWindowOffset = aws.Int64(int64(*backtestOffset))
+		}
+	}
 	res, err := svc.CreatePredictor(input)
 	if err != nil {
 		return err
@@ -330,6 +396,25 @@ func describePredictor(predictorArn string) error {
 	return nil
 }
 
+// getAccuracyMetrics prints the backtest metrics of every algorithm the predictor evaluated as JSON.
+func getAccuracyMetrics(predictorArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.GetAccuracyMetricsInput{
+		PredictorArn: aws.String(predictorArn),
+	}
+	res, err := svc.GetAccuracyMetrics(input)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := json.MarshalIndent(res.PredictorEvaluationResults, "", "  ")
+	if err != nil {
+		return err
+	}
+	log.Println(string(jsonBytes))
+	return nil
+}
+
 func describeForecast(forecastArn string) error {
 	svc := getForecastservice()
 
@@ -438,6 +523,71 @@ func deleteForecastExportJob(forecastExportJobArn string) error {
 	return nil
 }
 
+func deleteExplainability(explainabilityArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.DeleteExplainabilityInput{
+		ExplainabilityArn: aws.String(explainabilityArn),
+	}
+	_, err := svc.DeleteExplainability(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteExplainabilityExport(explainabilityExportArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.DeleteExplainabilityExportInput{
+		ExplainabilityExportArn: aws.String(explainabilityExportArn),
+	}
+	_, err := svc.DeleteExplainabilityExport(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteWhatIfAnalysis(whatIfAnalysisArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.DeleteWhatIfAnalysisInput{
+		WhatIfAnalysisArn: aws.String(whatIfAnalysisArn),
+	}
+	_, err := svc.DeleteWhatIfAnalysis(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteWhatIfForecast(whatIfForecastArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.DeleteWhatIfForecastInput{
+		WhatIfForecastArn: aws.String(whatIfForecastArn),
+	}
+	_, err := svc.DeleteWhatIfForecast(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteWhatIfForecastExport(whatIfForecastExportArn string) error {
+	svc := getForecastservice()
+
+	input := &forecastservice.DeleteWhatIfForecastExportInput{
+		WhatIfForecastExportArn: aws.String(whatIfForecastExportArn),
+	}
+	_, err := svc.DeleteWhatIfForecastExport(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func updateDatasetGroup(datasetArn string, datasetGroupArn string) error {
 	svc := getForecastservice()
 
@@ -498,7 +648,15 @@ func createBucket(name string) error {
 
 func uploadData(bucketName string, jsonData string) error {
 	t := time.Now()
-	stringData := "item_id,timestamp,target_value\n"
+	schema, err := constant.GetSchema()
+	if err != nil {
+		return err
+	}
+	header := []string{}
+	for _, v := range schema.Attributes {
+		header = append(header, v.Name)
+	}
+	stringData := strings.Join(header, ",") + "\n"
 	bucketPath := "csv"
 	contentType := "text/csv"
 	filename := t.Format(layout2) + ".csv"
@@ -514,7 +672,18 @@ func uploadData(bucketName string, jsonData string) error {
 	}
 	for i, v := range values {
 		t_ := t.AddDate(0, 0, i - len(values))
-		stringData += "v," + t_.Format(layout3) + "," + strconv.FormatFloat(v, 'f', -1, 64) + "\n"
+		row := make([]string, len(header))
+		for j, name := range header {
+			switch name {
+			case schema.ItemField() :
+				row[j] = "v"
+			case constant.TimestampField :
+				row[j] = t_.Format(layout3)
+			default :
+				row[j] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		stringData += strings.Join(row, ",") + "\n"
 	}
 	uploader := s3manager.NewUploader(sess)
 	_, err = uploader.Upload(&s3manager.UploadInput{
@@ -531,6 +700,387 @@ func uploadData(bucketName string, jsonData string) error {
 	return nil
 }
 
+func hasErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
+
+// datasetSuffixes are added to the run name for the related time series and item metadata datasets.
+var datasetSuffixes = []string{"", "_related", "_metadata"}
+
+func isRunResource(resourceName string, name string) bool {
+	for _, v := range datasetSuffixes {
+		if resourceName == name + v {
+			return true
+		}
+	}
+	return false
+}
+
+// isScenarioResource matches the what-if forecasts and exports of a run, named after the run and a scenario.
+func isScenarioResource(resourceName string, name string) bool {
+	return strings.HasPrefix(resourceName, name + "_")
+}
+
+// findArns returns the ARNs of the resources of a run by kind. Kinds without resources are left out.
+func findArns(name string)(map[string][]string, error) {
+	svc := getForecastservice()
+	arns := map[string][]string{}
+
+	err := svc.ListDatasetGroupsPages(&forecastservice.ListDatasetGroupsInput{}, func(page *forecastservice.ListDatasetGroupsOutput, lastPage bool) bool {
+		for _, v := range page.DatasetGroups {
+			if isRunResource(aws.StringValue(v.DatasetGroupName), name) {
+				arns["DatasetGroup"] = append(arns["DatasetGroup"], aws.StringValue(v.DatasetGroupArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListDatasetsPages(&forecastservice.ListDatasetsInput{}, func(page *forecastservice.ListDatasetsOutput, lastPage bool) bool {
+		for _, v := range page.Datasets {
+			if isRunResource(aws.StringValue(v.DatasetName), name) {
+				arns["Dataset"] = append(arns["Dataset"], aws.StringValue(v.DatasetArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListDatasetImportJobsPages(&forecastservice.ListDatasetImportJobsInput{}, func(page *forecastservice.ListDatasetImportJobsOutput, lastPage bool) bool {
+		for _, v := range page.DatasetImportJobs {
+			if isRunResource(aws.StringValue(v.DatasetImportJobName), name) {
+				arns["DatasetImportJob"] = append(arns["DatasetImportJob"], aws.StringValue(v.DatasetImportJobArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListPredictorsPages(&forecastservice.ListPredictorsInput{}, func(page *forecastservice.ListPredictorsOutput, lastPage bool) bool {
+		for _, v := range page.Predictors {
+			if isRunResource(aws.StringValue(v.PredictorName), name) {
+				arns["Predictor"] = append(arns["Predictor"], aws.StringValue(v.PredictorArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListForecastsPages(&forecastservice.ListForecastsInput{}, func(page *forecastservice.ListForecastsOutput, lastPage bool) bool {
+		for _, v := range page.Forecasts {
+			if isRunResource(aws.StringValue(v.ForecastName), name) {
+				arns["Forecast"] = append(arns["Forecast"], aws.StringValue(v.ForecastArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListForecastExportJobsPages(&forecastservice.ListForecastExportJobsInput{}, func(page *forecastservice.ListForecastExportJobsOutput, lastPage bool) bool {
+		for _, v := range page.ForecastExportJobs {
+			if isRunResource(aws.StringValue(v.ForecastExportJobName), name) {
+				arns["ForecastExportJob"] = append(arns["ForecastExportJob"], aws.StringValue(v.ForecastExportJobArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListExplainabilitiesPages(&forecastservice.ListExplainabilitiesInput{}, func(page *forecastservice.ListExplainabilitiesOutput, lastPage bool) bool {
+		for _, v := range page.Explainabilities {
+			if isRunResource(aws.StringValue(v.ExplainabilityName), name) {
+				arns["Explainability"] = append(arns["Explainability"], aws.StringValue(v.ExplainabilityArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListExplainabilityExportsPages(&forecastservice.ListExplainabilityExportsInput{}, func(page *forecastservice.ListExplainabilityExportsOutput, lastPage bool) bool {
+		for _, v := range page.ExplainabilityExports {
+			if isRunResource(aws.StringValue(v.ExplainabilityExportName), name) {
+				arns["ExplainabilityExport"] = append(arns["ExplainabilityExport"], aws.StringValue(v.ExplainabilityExportArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListWhatIfAnalysesPages(&forecastservice.ListWhatIfAnalysesInput{}, func(page *forecastservice.ListWhatIfAnalysesOutput, lastPage bool) bool {
+		for _, v := range page.WhatIfAnalyses {
+			if isRunResource(aws.StringValue(v.WhatIfAnalysisName), name) {
+				arns["WhatIfAnalysis"] = append(arns["WhatIfAnalysis"], aws.StringValue(v.WhatIfAnalysisArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListWhatIfForecastsPages(&forecastservice.ListWhatIfForecastsInput{}, func(page *forecastservice.ListWhatIfForecastsOutput, lastPage bool) bool {
+		for _, v := range page.WhatIfForecasts {
+			if isScenarioResource(aws.StringValue(v.WhatIfForecastName), name) {
+				arns["WhatIfForecast"] = append(arns["WhatIfForecast"], aws.StringValue(v.WhatIfForecastArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = svc.ListWhatIfForecastExportsPages(&forecastservice.ListWhatIfForecastExportsInput{}, func(page *forecastservice.ListWhatIfForecastExportsOutput, lastPage bool) bool {
+		for _, v := range page.WhatIfForecastExports {
+			if isScenarioResource(aws.StringValue(v.WhatIfForecastExportName), name) {
+				arns["WhatIfForecastExport"] = append(arns["WhatIfForecastExport"], aws.StringValue(v.WhatIfForecastExportArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return arns, nil
+}
+
+// waitDeleted waits until the resource is no longer being created, issues del once and polls its
+// status until it is gone. It gives up after deleteTimeout.
+func waitDeleted(kind string, arn string, del func(string) error) error {
+	deadline := time.Now().Add(deleteTimeout)
+	deleting := false
+	for {
+		status, err := describeStatus(kind, arn)
+		if hasErrorCode(err, forecastservice.ErrCodeResourceNotFoundException) {
+			log.Println("[" + kind + "] deleted")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if status == "DELETE_FAILED" {
+			return errors.New("Error: " + kind + " " + arn + " could not be deleted.")
+		}
+		busy := strings.HasSuffix(status, "_PENDING") || strings.HasSuffix(status, "_IN_PROGRESS") || strings.HasSuffix(status, "_STOPPING")
+		if !deleting && !busy {
+			err := del(arn)
+			if hasErrorCode(err, forecastservice.ErrCodeResourceNotFoundException) {
+				log.Println("[" + kind + "] deleted")
+				return nil
+			} else if err != nil && !hasErrorCode(err, forecastservice.ErrCodeResourceInUseException) {
+				return err
+			}
+			deleting = err == nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("Error: " + kind + " " + arn + " is still " + status + ".")
+		}
+		time.Sleep(deleteInterval)
+	}
+}
+
+// describeStatus returns the status of a resource that deleteRun deletes.
+func describeStatus(kind string, arn string)(string, error) {
+	svc := getForecastservice()
+
+	switch kind {
+	case "ForecastExportJob":
+		res, err := svc.DescribeForecastExportJob(&forecastservice.DescribeForecastExportJobInput{ForecastExportJobArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "WhatIfForecastExport":
+		res, err := svc.DescribeWhatIfForecastExport(&forecastservice.DescribeWhatIfForecastExportInput{WhatIfForecastExportArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "WhatIfForecast":
+		res, err := svc.DescribeWhatIfForecast(&forecastservice.DescribeWhatIfForecastInput{WhatIfForecastArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "WhatIfAnalysis":
+		res, err := svc.DescribeWhatIfAnalysis(&forecastservice.DescribeWhatIfAnalysisInput{WhatIfAnalysisArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "Forecast":
+		res, err := svc.DescribeForecast(&forecastservice.DescribeForecastInput{ForecastArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "ExplainabilityExport":
+		res, err := svc.DescribeExplainabilityExport(&forecastservice.DescribeExplainabilityExportInput{ExplainabilityExportArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "Explainability":
+		res, err := svc.DescribeExplainability(&forecastservice.DescribeExplainabilityInput{ExplainabilityArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "Predictor":
+		res, err := svc.DescribePredictor(&forecastservice.DescribePredictorInput{PredictorArn: aws.String(arn)})
+		if hasErrorCode(err, forecastservice.ErrCodeInvalidInputException) {
+			// AutoPredictors are only described by DescribeAutoPredictor.
+			auto, err := svc.DescribeAutoPredictor(&forecastservice.DescribeAutoPredictorInput{PredictorArn: aws.String(arn)})
+			if err != nil {
+				return "", err
+			}
+			return aws.StringValue(auto.Status), nil
+		} else if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "DatasetImportJob":
+		res, err := svc.DescribeDatasetImportJob(&forecastservice.DescribeDatasetImportJobInput{DatasetImportJobArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "Dataset":
+		res, err := svc.DescribeDataset(&forecastservice.DescribeDatasetInput{DatasetArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	case "DatasetGroup":
+		res, err := svc.DescribeDatasetGroup(&forecastservice.DescribeDatasetGroupInput{DatasetGroupArn: aws.String(arn)})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(res.Status), nil
+	}
+	return "", errors.New("Error: Unknown Resource " + kind + ".")
+}
+
+// deleteRun deletes every resource of a senddata run in dependency order and waits for each delete to
+// finish. Resources that are already gone are skipped, so an interrupted deleteRun can be run again.
+func deleteRun(bucketName string, progressId string) error {
+	// The progress id is a prefix of every object of the run, so a partial id would match other runs.
+	if len(progressId) != 17 || len(strings.Trim(progressId, "0123456789")) > 0 {
+		return errors.New("Error: Invalid ProgressId.")
+	}
+	name := idPrefix + progressId
+	arns, err := findArns(name)
+	if err != nil {
+		return err
+	}
+	steps := []struct {
+		kind string
+		del  func(string) error
+	}{
+		{"ForecastExportJob", deleteForecastExportJob},
+		{"WhatIfForecastExport", deleteWhatIfForecastExport},
+		{"WhatIfForecast", deleteWhatIfForecast},
+		{"WhatIfAnalysis", deleteWhatIfAnalysis},
+		{"Forecast", deleteForecast},
+		{"ExplainabilityExport", deleteExplainabilityExport},
+		{"Explainability", deleteExplainability},
+		{"Predictor", deletePredictor},
+		{"DatasetImportJob", deleteDatasetImportJob},
+		{"Dataset", deleteDataset},
+		{"DatasetGroup", deleteDatasetGroup},
+	}
+	for _, v := range steps {
+		if len(arns[v.kind]) == 0 {
+			log.Println("[" + v.kind + "] not found")
+			continue
+		}
+		for _, arn := range arns[v.kind] {
+			log.Println("[" + v.kind + "] " + arn)
+			if err := waitDeleted(v.kind, arn, v.del); err != nil {
+				return err
+			}
+		}
+	}
+	return deleteRunObjects(bucketName, name)
+}
+
+// deleteRunObjects removes the uploaded datasets, exported result, explainability and scenarios of a run and marks its manifest DELETED.
+func deleteRunObjects(bucketName string, name string) error {
+	svc := getS3()
+
+	keys := []string{}
+	for _, v := range datasetSuffixes {
+		keys = append(keys, "csv/" + name + v + ".csv")
+	}
+	for _, prefix := range []string{"result/", "explainability/", "whatif/"} {
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucketName),
+			Prefix: aws.String(prefix + name + "/"),
+		}
+		err := svc.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, v := range page.Contents {
+				keys = append(keys, aws.StringValue(v.Key))
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range keys {
+		_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key: aws.String(v),
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("[" + v + "] deleted")
+	}
+
+	runKey := "runs/" + name + ".json"
+	res, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key: aws.String(runKey),
+	})
+	if hasErrorCode(err, s3.ErrCodeNoSuchKey) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	run := map[string]interface{}{}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return err
+	}
+	run["status"] = "DELETED"
+	run["stage"] = ""
+	run["arns"] = map[string]string{}
+	run["updated_at"] = time.Now()
+	data, err = json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		ACL: aws.String("private"),
+		Bucket: aws.String(bucketName),
+		Key: aws.String(runKey),
+		Body: bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
 func main() {
 	log.Println("[ Forecast Management ]")
 	flag.Parse()
@@ -620,6 +1170,12 @@ func main() {
 		} else if err := describePredictor(flag.Arg(1)); err != nil {
 			log.Fatal(err)
 		}
+	case "getAccuracyMetrics":
+		if len(flag.Args()) < 2 {
+			log.Fatal("Error: No PredictorArn.")
+		} else if err := getAccuracyMetrics(flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
 	case "describeForecast":
 		if len(flag.Args()) < 2 {
 			log.Fatal("Error: No ForecastArn.")
@@ -674,6 +1230,12 @@ func main() {
 		} else if err := updateDatasetGroup(flag.Arg(1), flag.Arg(2)); err != nil {
 			log.Fatal(err)
 		}
+	case "deleteRun":
+		if len(flag.Args()) < 3 {
+			log.Fatal("Error: No BucketName, ProgressId.")
+		} else if err := deleteRun(flag.Arg(1), strings.TrimPrefix(flag.Arg(2), idPrefix)); err != nil {
+			log.Fatal(err)
+		}
 	case "createBucket":
 		if len(flag.Args()) < 2 {
 			log.Fatal("Error: No Bucket Name.")
@@ -702,7 +1264,7 @@ func main() {
 		log.Println("forecast: {list}{DatasetGroups|Datasets|DatasetImportJobs|Predictors|Forecasts|ForecastExportJobs}")
 		log.Println("forecast: {describe}{DatasetGroup|Dataset|DatasetImportJob|Predictor|Forecast|ForecastExportJob}")
 		log.Println("forecast: {delete}{DatasetGroup|Dataset|DatasetImportJob|Predictor|Forecast|ForecastExportJob}")
-		log.Println("forecast: updateDatasetGroup")
+		log.Println("forecast: updateDatasetGroup , deleteRun")
 		log.Println("s3: createBucket , uploadData , listBuckets , listObjects")
 	}
 
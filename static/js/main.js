@@ -19,6 +19,22 @@ var drawChart = function() {
           borderColor: "rgba(210,210,210,1)",
           backgroundColor: "rgba(0,0,0,0)",
           pointBackgroundColor: generatePointBgcolor(App.data.length)
+        },
+        {
+          label: 'Lower',
+          data: App.band.lower,
+          borderColor: "rgba(0,0,255,0.2)",
+          backgroundColor: "rgba(0,0,255,0.1)",
+          pointRadius: 0,
+          fill: false
+        },
+        {
+          label: 'Upper',
+          data: App.band.upper,
+          borderColor: "rgba(0,0,255,0.2)",
+          backgroundColor: "rgba(0,0,255,0.1)",
+          pointRadius: 0,
+          fill: '-1'
         }
       ],
     },
@@ -32,7 +48,7 @@ var drawChart = function() {
       scales: {
         yAxes: [{
           ticks: {
-            suggestedMax: Math.max(...App.data),
+            suggestedMax: Math.max(...App.data, ...App.band.upper.filter(v => v !== null)),
             suggestedMin: 0,
             stepSize: 0.1,
             callback: function(value, index, values){
@@ -50,11 +66,14 @@ var SubmitForm = function(action) {
   $("#loader").addClass('active');
   var data = JSON.stringify(App.data);
   const data_ = {action, data};
+  App.explain = $("#explain").prop("checked");
+  if (App.explain) {
+    data_.explain = "true";
+  }
   request(data_, (res)=>{
     App.pid = res.message;
-    $("#result").text("Data-Import process. Please wait.");
+    $("#result").text(App.stageMessages.checkimport);
     $("#info").removeClass("hidden").addClass("visible");
-    App.progress = "checkimport";
     CheckProgress();
   }, (e)=>{
     console.log(e.responseJSON.message);
@@ -64,8 +83,8 @@ var SubmitForm = function(action) {
   });
 };
 
-var CheckProgress = function(action) {
-  var action = App.progress;
+var CheckProgress = function() {
+  var action = "checkrun";
   var id = App.pid;
   if (!id) {
     $("#warning").text("Progress ID is Empty").removeClass("hidden").addClass("visible");
@@ -73,31 +92,21 @@ var CheckProgress = function(action) {
   }
   const data = {action, id};
   request(data, (res)=>{
-    if (res.message == "ACTIVE") {
-      switch (App.progress){
-      case "checkimport":
-        App.progress = "checkpredictor";
-        $("#result").text("Predictor process. Please wait.");
-        CheckProgress();
-        break;
-      case "checkpredictor":
-        App.progress = "checkforecast";
-        $("#result").text("Forecast process. Please wait.");
-        CheckProgress();
-        break;
-      case "checkforecast":
-        App.progress = "checkexport";
-        $("#result").text("Data-Export process. Please wait.");
-        CheckProgress();
-        break;
-      case "checkexport":
-        $("#result").text("Result will be shown. Please wait.");
-        GetResult();
-        break;
-      }
-    } else if (res.message.endsWith('FAILED')) {
-      $("#warning").text("Error: " + App.progress + " Failed").removeClass("hidden").addClass("visible");
-    } else {
+    if (res.run.status == "RUNNING" && res.run.stage == "checkexport") {
+      // The forecast is ACTIVE, so it can be queried without waiting for the export.
+      QueryForecast();
+      return;
+    }
+    switch (res.run.status){
+    case "DONE":
+      $("#result").text("Result will be shown. Please wait.");
+      GetResult();
+      break;
+    case "FAILED":
+      $("#warning").text("Error: " + res.run.stage + " Failed").removeClass("hidden").addClass("visible");
+      break;
+    default:
+      $("#result").text(App.stageMessages[res.run.stage]);
       setTimeout(function() {
         CheckProgress();
       }, 300000);
@@ -110,6 +119,22 @@ var CheckProgress = function(action) {
   });
 };
 
+var QueryForecast = function() {
+  var action  = "queryforecast";
+  var id = App.pid;
+  const data = {action, id};
+  request(data, (res)=>{
+    ShowResult(res);
+  }, (e)=>{
+    // Fall back to waiting for the export.
+    console.log(e.responseJSON.message);
+    $("#result").text(App.stageMessages.checkexport);
+    setTimeout(function() {
+      CheckProgress();
+    }, 300000);
+  });
+};
+
 var GetResult = function() {
   var action  = "getresult";
   var id = App.pid;
@@ -119,18 +144,7 @@ var GetResult = function() {
   }
   const data = {action, id};
   request(data, (res)=>{
-    $(".submitbutton").removeClass('disabled');
-    $("#loader").removeClass('active');
-    App.resultRange = 10;
-    try {
-      resData = JSON.parse(res.message);
-      App.data = App.data.concat(resData);
-      clearChart();
-      drawChart();
-      $("#result").text("Result data is shown blue dot.");
-    } catch(e) {
-      $("#warning").text("Result data parse Error.").removeClass("hidden").addClass("visible");
-    }
+    ShowResult(res);
   }, (e)=>{
     console.log(e.responseJSON.message);
     $("#warning").text(e.responseJSON.message).removeClass("hidden").addClass("visible");
@@ -139,6 +153,134 @@ var GetResult = function() {
   });
 };
 
+var ShowResult = function(res) {
+  $(".submitbutton").removeClass('disabled');
+  $("#loader").removeClass('active');
+  try {
+    const itemIds = Object.keys(res.result).sort();
+    const result = res.result[itemIds[0]];
+    const names = sortQuantiles(Object.keys(result[0].quantiles));
+    const median = names.includes("p50") ? "p50" : names[Math.floor(names.length / 2)];
+    const padding = Array(App.data.length - 1).fill(null);
+    const last = App.data[App.data.length - 1];
+    App.band.lower = padding.concat([last], result.map(v => v.quantiles[names[0]]));
+    App.band.upper = padding.concat([last], result.map(v => v.quantiles[names[names.length - 1]]));
+    App.data = App.data.concat(result.map(v => v.quantiles[median]));
+    App.resultRange = result.length;
+    clearChart();
+    drawChart();
+    $("#result").text("Result data is shown blue dot.");
+    GetMetrics();
+    if (App.explain) {
+      CheckExplainability();
+    }
+  } catch(e) {
+    $("#warning").text("Result data parse Error.").removeClass("hidden").addClass("visible");
+  }
+};
+
+var GetMetrics = function() {
+  var action  = "getmetrics";
+  var id = App.pid;
+  const data = {action, id};
+  request(data, (res)=>{
+    const windows = res.metrics && res.metrics.length > 0 ? res.metrics[0].windows : [];
+    const summary = windows.find(v => v.type == "SUMMARY") || windows[0];
+    if (!summary || !summary.error_metrics) {
+      return;
+    }
+    const metric = summary.error_metrics.find(v => v.forecast_type == "mean") || summary.error_metrics.find(v => v.forecast_type == "0.5") || summary.error_metrics[0];
+    const format = (name, v) => v === undefined ? null : name + " " + v.toFixed(3);
+    const text = [
+      format("WAPE", metric.wape),
+      format("RMSE", metric.rmse),
+      format("MAPE", metric.mape),
+      format("MASE", metric.mase),
+      format("wQL", summary.average_weighted_quantile_loss)
+    ].filter(v => v !== null).join(" / ");
+    $("#metrics").text("Model accuracy (backtest, " + metric.forecast_type + "): " + text);
+  }, (e)=>{
+    console.log(e.responseJSON.message);
+  });
+};
+
+var CheckExplainability = function() {
+  var action  = "checkexplainability";
+  var id = App.pid;
+  const data = {action, id};
+  request(data, (res)=>{
+    if (res.message == "ACTIVE") {
+      GetExplainability();
+      return;
+    }
+    $("#explainability").text("Explainability process. Please wait.");
+    setTimeout(function() {
+      CheckExplainability();
+    }, 300000);
+  }, (e)=>{
+    console.log(e.responseJSON.message);
+    $("#explainability").text("Explainability Error: " + e.responseJSON.message);
+  });
+};
+
+var GetExplainability = function() {
+  var action  = "getexplainability";
+  var id = App.pid;
+  const data = {action, id};
+  request(data, (res)=>{
+    const impacts = res.impacts || [];
+    $("#explainability").text(impacts.length > 0 ? "Attribute impact on the forecast." : "No attribute to explain.");
+    if (impacts.length > 0) {
+      drawBarChart(impacts);
+    }
+  }, (e)=>{
+    console.log(e.responseJSON.message);
+    $("#explainability").text("Explainability Error: " + e.responseJSON.message);
+  });
+};
+
+var drawBarChart = function(impacts) {
+  $("#barSegment").show();
+  if (window.barChart) {
+    window.barChart.destroy();
+  }
+  var ctx = document.getElementById("barChart");
+  window.barChart = new Chart(ctx, {
+    type: 'horizontalBar',
+    data: {
+      labels: impacts.map(v => v.attribute),
+      datasets: [
+        {
+          label: 'Impact',
+          data: impacts.map(v => v.impact),
+          backgroundColor: impacts.map(v => v.impact < 0 ? "rgba(255,0,0,0.5)" : "rgba(0,0,255,0.5)")
+        }
+      ],
+    },
+    options: {
+      legend: {
+        display: false
+      },
+      scales: {
+        xAxes: [{
+          ticks: {
+            suggestedMax: 1,
+            suggestedMin: -1
+          }
+        }]
+      },
+    }
+  });
+};
+
+var sortQuantiles = function(names) {
+  const quantiles = names.filter(v => /^p\d+$/.test(v));
+  if (quantiles.length == 0) {
+    return names;
+  }
+  return quantiles.sort((a, b) => parseInt(a.slice(1), 10) - parseInt(b.slice(1), 10));
+};
+
 var request = function(data, callback, onerror) {
   $.ajax({
     type:          'POST',
@@ -198,8 +340,8 @@ var ChangeText = function() {
 
 var CheckData = function(dataString) {
   var data = []
-  const mn = 30
-  const mx = 100
+  const mn = App.minPoints
+  const mx = App.maxPoints
   try {
     data = JSON.parse('[' + dataString + ']');
   } catch(e) {
@@ -220,6 +362,7 @@ var CheckData = function(dataString) {
 var UpdateData = function(data) {
   App.resultRange = 0;
   App.data = data;
+  App.band = {lower: [], upper: []};
   $("#warning").text("").removeClass("visible").addClass("hidden");
   clearChart();
   drawChart();
@@ -242,8 +385,17 @@ var App = {
   sin: [0, 0.062791, 0.125333, 0.187381, 0.24869, 0.309017, 0.368125, 0.425779, 0.481754, 0.535827, 0.587785, 0.637424, 0.684547, 0.728969, 0.770513, 0.809017, 0.844328, 0.876307, 0.904827, 0.929776, 0.951057, 0.968583, 0.982287, 0.992115, 0.998027, 1, 0.998027, 0.992115, 0.982287, 0.968583, 0.951057, 0.929776, 0.904827, 0.876307, 0.844328, 0.809017, 0.770513, 0.728969, 0.684547, 0.637424, 0.587785, 0.535827, 0.481754, 0.425779, 0.368125, 0.309017, 0.24869, 0.187381, 0.125333, 0.062791, 0, -0.062791, -0.125333, -0.187381, -0.24869, -0.309017, -0.368125, -0.425779, -0.481754, -0.535827, -0.587785, -0.637424, -0.684547, -0.728969, -0.770513, -0.809017, -0.844328, -0.876307, -0.904827, -0.929776, -0.951057, -0.968583, -0.982287, -0.992115, -0.998027, -1, -0.998027, -0.992115, -0.982287, -0.968583, -0.951057, -0.929776, -0.904827, -0.876307, -0.844328, -0.809017, -0.770513, -0.728969, -0.684547, -0.637424, -0.587785, -0.535827, -0.481754, -0.425779, -0.368125, -0.309017, -0.24869, -0.187381, -0.125333, -0.062791],
   cos: [1, 0.998027, 0.992115, 0.982287, 0.968583, 0.951057, 0.929776, 0.904827, 0.876307, 0.844328, 0.809017, 0.770513, 0.728969, 0.684547, 0.637424, 0.587785, 0.535827, 0.481754, 0.425779, 0.368125, 0.309017, 0.24869, 0.187381, 0.125333, 0.062791, 0, -0.062791, -0.125333, -0.187381, -0.24869, -0.309017, -0.368125, -0.425779, -0.481754, -0.535827, -0.587785, -0.637424, -0.684547, -0.728969, -0.770513, -0.809017, -0.844328, -0.876307, -0.904827, -0.929776, -0.951057, -0.968583, -0.982287, -0.992115, -0.998027, -1, -0.998027, -0.992115, -0.982287, -0.968583, -0.951057, -0.929776, -0.904827, -0.876307, -0.844328, -0.809017, -0.770513, -0.728969, -0.684547, -0.637424, -0.587785, -0.535827, -0.481754, -0.425779, -0.368125, -0.309017, -0.24869, -0.187381, -0.125333, -0.062791, -0, 0.062791, 0.125333, 0.187381, 0.24869, 0.309017, 0.368125, 0.425779, 0.481754, 0.535827, 0.587785, 0.637424, 0.684547, 0.728969, 0.770513, 0.809017, 0.844328, 0.876307, 0.904827, 0.929776, 0.951057, 0.968583, 0.982287, 0.992115, 0.998027],
   lin: [0, 0.01, 0.02, 0.03, 0.04, 0.05, 0.06, 0.07, 0.08, 0.09, 0.1, 0.11, 0.12, 0.13, 0.14, 0.15, 0.16, 0.17, 0.18, 0.19, 0.2, 0.21, 0.22, 0.23, 0.24, 0.25, 0.26, 0.27, 0.28, 0.29, 0.3, 0.31, 0.32, 0.33, 0.34, 0.35, 0.36, 0.37, 0.38, 0.39, 0.4, 0.41, 0.42, 0.43, 0.44, 0.45, 0.46, 0.47, 0.48, 0.49, 0.5, 0.51, 0.52, 0.53, 0.54, 0.55, 0.56, 0.57, 0.58, 0.59, 0.6, 0.61, 0.62, 0.63, 0.64, 0.65, 0.66, 0.67, 0.68, 0.69, 0.7, 0.71, 0.72, 0.73, 0.74, 0.75, 0.76, 0.77, 0.78, 0.79, 0.8, 0.81, 0.82, 0.83, 0.84, 0.85, 0.86, 0.87, 0.88, 0.89, 0.9, 0.91, 0.92, 0.93, 0.94, 0.95, 0.96, 0.97, 0.98, 0.99],
+  band: {lower: [], upper: []},
   resultRange: 0,
   pid: "",
-  progress: "",
+  explain: false,
+  stageMessages: {
+    checkimport: "Data-Import process. Please wait.",
+    checkpredictor: "Predictor process. Please wait.",
+    checkforecast: "Forecast process. Please wait.",
+    checkexport: "Data-Export process. Please wait.",
+  },
+  minPoints: {{ .MinPoints }},
+  maxPoints: {{ .MaxPoints }},
   url: location.origin + {{ .ApiPath }},
 };